@@ -0,0 +1,146 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTOCIDRegexp(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"found", "[CTDB TOCID: mGBBRsSWAy.ghMgcxi_PWbbB3UQ-] found", "mGBBRsSWAy.ghMgcxi_PWbbB3UQ-"},
+		{"found with period", "[CTDB TOCID: Zp6kqJ7nEAL1TlWx7gqk2WXWIwE-] found.", "Zp6kqJ7nEAL1TlWx7gqk2WXWIwE-"},
+		{"submit result", "[CTDB TOCID: mGBBRsSWAy.ghMgcxi_PWbbB3UQ-] found, Submit result: already submitted", "mGBBRsSWAy.ghMgcxi_PWbbB3UQ-"},
+		{"not present", "[CTDB TOCID: 0Gj5Nz8hrvIPOaEbGyZ1h0GPv.g-] disk not present in database", "0Gj5Nz8hrvIPOaEbGyZ1h0GPv.g-"},
+		{"no trailing wording", "[CTDB TOCID: 0Gj5Nz8hrvIPOaEbGyZ1h0GPv.g-]", "0Gj5Nz8hrvIPOaEbGyZ1h0GPv.g-"},
+		{"multiple brackets", "[CTDB TOCID: Vb3vCR4qHzKpCF_gUeS0Lh8n5ss-] found, submitted with [CTDB ID: 7f3e2a10] (31/31)", "Vb3vCR4qHzKpCF_gUeS0Lh8n5ss-"},
+		{"bracket before", "[AccurateRip ID: 0012abcd-00abcdef-a00b1c0d] [CTDB TOCID: Zp6kqJ7nEAL1TlWx7gqk2WXWIwE-] found.", "Zp6kqJ7nEAL1TlWx7gqk2WXWIwE-"},
+		{"extra spaces", "[CTDB  TOCID:   Zp6kqJ7nEAL1TlWx7gqk2WXWIwE-  ] found", "Zp6kqJ7nEAL1TlWx7gqk2WXWIwE-"},
+		{"wrapped after CTDB", "[CTDB\r\nTOCID: Vb3vCR4qHzKpCF_gUeS0Lh8n5ss-] found", "Vb3vCR4qHzKpCF_gUeS0Lh8n5ss-"},
+		{"wrapped after TOCID", "[CTDB TOCID:\nVb3vCR4qHzKpCF_gUeS0Lh8n5ss-] found", "Vb3vCR4qHzKpCF_gUeS0Lh8n5ss-"},
+		{"missing TOCID", "[CTDB TOCID: ] found", ""},
+		{"no CTDB", "[AccurateRip ID: 0012abcd-00abcdef-a00b1c0d] found.", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if matches := tocIDRegexp.FindStringSubmatch(tt.line); len(matches) > 0 {
+				got = matches[1]
+			}
+			if got != tt.want {
+				t.Errorf("TOCID of %q = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectAccuripVersions(t *testing.T) {
+	tests := []struct {
+		fileName   string
+		tocID      string
+		confidence int
+	}{
+		{"eac-1.0b1.log", "0Gj5Nz8hrvIPOaEbGyZ1h0GPv.g-", 5},
+		{"eac-1.0b3.log", "mGBBRsSWAy.ghMgcxi_PWbbB3UQ-", 12},
+		{"eac-1.6.log", "Vb3vCR4qHzKpCF_gUeS0Lh8n5ss-", 31}, // UTF-16 with the TOCID line wrapped
+		{"cuetools-2.1.6.accurip", "Zp6kqJ7nEAL1TlWx7gqk2WXWIwE-", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			result, detectErr := detectAccuripInFile(os.DirFS(filepath.Join("testdata", "logs")), tt.fileName)
+			if detectErr != nil {
+				t.Fatalf("detectAccuripInFile() error = %v", detectErr)
+			}
+
+			if result.tocID != tt.tocID {
+				t.Errorf("tocID = %q, want %q", result.tocID, tt.tocID)
+			}
+			if result.status != AccuripConfirmed {
+				t.Errorf("status = %s, want %s", result.status, AccuripConfirmed)
+			}
+			if result.confidence != tt.confidence {
+				t.Errorf("confidence = %d, want %d", result.confidence, tt.confidence)
+			}
+		})
+	}
+}
+
+func TestClassifyAccuripOutcomes(t *testing.T) {
+	defer func(requireConfirm bool) { *flagRequireConfirm = requireConfirm }(*flagRequireConfirm)
+
+	tests := []struct {
+		fileName string
+		status   AccuripStatus
+		verified bool // without -require-confirmed
+		strict   bool // with -require-confirmed
+	}{
+		{"outcome-confirmed.log", AccuripConfirmed, true, true},
+		{"outcome-present.log", AccuripPresent, true, false},
+		{"outcome-not-present.log", AccuripNotVerified, false, false},
+		{"outcome-mismatch.log", AccuripNotVerified, false, false},
+		{"cuetools-no-match.accurip", AccuripNotVerified, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			result, detectErr := detectAccuripInFile(os.DirFS(filepath.Join("testdata", "logs")), tt.fileName)
+			if detectErr != nil {
+				t.Fatalf("detectAccuripInFile() error = %v", detectErr)
+			}
+			if len(result.tocID) == 0 {
+				t.Fatalf("no TOCID detected")
+			}
+
+			if result.status != tt.status {
+				t.Errorf("status = %s, want %s", result.status, tt.status)
+			}
+
+			*flagRequireConfirm = false
+			if result.verified() != tt.verified {
+				t.Errorf("verified() = %v, want %v", result.verified(), tt.verified)
+			}
+
+			*flagRequireConfirm = true
+			if result.verified() != tt.strict {
+				t.Errorf("verified() with -require-confirmed = %v, want %v", result.verified(), tt.strict)
+			}
+		})
+	}
+}
+
+func TestDetectAccuripGzip(t *testing.T) {
+	logs := os.DirFS(filepath.Join("testdata", "logs"))
+
+	result, detectErr := detectAccuripInFile(logs, "eac-1.0b3.log.gz")
+	if detectErr != nil {
+		t.Fatalf("detectAccuripInFile() error = %v", detectErr)
+	}
+	if result.tocID != "mGBBRsSWAy.ghMgcxi_PWbbB3UQ-" || result.status != AccuripConfirmed {
+		t.Errorf("result = %q %s, want mGBBRsSWAy.ghMgcxi_PWbbB3UQ- %s", result.tocID, result.status, AccuripConfirmed)
+	}
+
+	// the gzipped log is picked up as the rip log of its folder
+	contents, readErr := fs.ReadFile(logs, "eac-1.0b3.log.gz")
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+
+	mf, crawlErr := crawlFolder(fstest.MapFS{
+		"Album/01 Track.flac": {Data: testFlac(301)},
+		"Album/rip.log.gz":    {Data: contents},
+	}, "/detect-accurip-gzip", "Album", nil)
+	if crawlErr != nil {
+		t.Fatalf("crawlFolder() error = %v", crawlErr)
+	}
+	if !mf.HasAccurip || mf.TocID != "mGBBRsSWAy.ghMgcxi_PWbbB3UQ-" {
+		t.Errorf("HasAccurip = %v TocID = %q, want the TOCID of the gzipped log", mf.HasAccurip, mf.TocID)
+	}
+}
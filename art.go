@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/image/draw"
+)
+
+// scaledJpegQuality is the quality used when re-encoding scaled jpeg images
+const scaledJpegQuality = 90
+
+// scaleArt writes a copy of an image scaled down to fit within maxDimension pixels into tmpDir,
+// preserving the aspect ratio. It returns an empty path if the image is already small enough.
+func scaleArt(p string, maxDimension int, tmpDir string) (string, error) {
+	f, openErr := os.Open(p)
+	if openErr != nil {
+		return "", fmt.Errorf("error opening image %s: %s", p, openErr)
+	}
+	defer f.Close()
+
+	src, format, decodeErr := image.Decode(f)
+	if decodeErr != nil {
+		return "", fmt.Errorf("error decoding image %s: %s", p, decodeErr)
+	}
+
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= maxDimension && h <= maxDimension {
+		return "", nil
+	}
+
+	if w > h {
+		w, h = maxDimension, h*maxDimension/w
+	} else {
+		w, h = w*maxDimension/h, maxDimension
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	out, createErr := os.CreateTemp(tmpDir, "*"+filepath.Ext(p))
+	if createErr != nil {
+		return "", fmt.Errorf("error creating scaled image for %s: %s", p, createErr)
+	}
+	defer out.Close()
+
+	var encodeErr error
+	switch format {
+	case "png":
+		encodeErr = png.Encode(out, dst)
+	default:
+		encodeErr = jpeg.Encode(out, dst, &jpeg.Options{Quality: scaledJpegQuality})
+	}
+	if encodeErr != nil {
+		return "", fmt.Errorf("error encoding scaled image for %s: %s", p, encodeErr)
+	}
+
+	return out.Name(), nil
+}
@@ -3,8 +3,10 @@ package beets
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"net/url"
+	"os"
 	"path/filepath"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -20,6 +22,7 @@ type AlbumSummary struct {
 	ID     int    `json:"id"`
 	Title  string `json:"title"`
 	Artist string `json:"artist"`
+	Genre  string `json:"genre"`
 }
 
 // Album represents an album with tracks
@@ -47,8 +50,9 @@ type item struct {
 // Beets interface for beets database access
 type Beets interface {
 	GetAllAlbums() ([]AlbumSummary, error)
+	GetAlbumsByAttribute(key, value string) ([]AlbumSummary, error)
 	GetAlbum(albumID int) (*Album, error)
-	PrintTableInfo(tableName string)
+	PrintTableInfo(tableName string) error
 }
 
 // beets is the implementation of the Beets interface
@@ -58,10 +62,10 @@ type beets struct {
 }
 
 // PrintTableInfo prints the table info for the given table name
-func (b *beets) PrintTableInfo(tableName string) {
+func (b *beets) PrintTableInfo(tableName string) error {
 	rows, err := b.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", tableName))
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("error querying table info from beets database %s", err)
 	}
 	defer rows.Close()
 
@@ -71,7 +75,7 @@ func (b *beets) PrintTableInfo(tableName string) {
 		var name, ctype string
 		var dflt_value sql.NullString
 		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dflt_value, &pk); err != nil {
-			log.Fatal(err)
+			return fmt.Errorf("error reading table info from beets database %s", err)
 		}
 		defaultValue := "NULL"
 		if dflt_value.Valid {
@@ -81,8 +85,10 @@ func (b *beets) PrintTableInfo(tableName string) {
 	}
 
 	if err := rows.Err(); err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("error reading table info from beets database %s", err)
 	}
+
+	return nil
 }
 
 // GetAlbums reads the albums from the beets database
@@ -90,7 +96,7 @@ func (b *beets) GetAllAlbums() ([]AlbumSummary, error) {
 
 	albums := []AlbumSummary{}
 
-	rows, err := b.db.Query(`SELECT id, albumartist, album FROM albums`)
+	rows, err := b.db.Query(`SELECT id, albumartist, album, genre FROM albums`)
 	if err != nil {
 		return nil, fmt.Errorf("error querying albums from beets database %s", err)
 	}
@@ -98,8 +104,8 @@ func (b *beets) GetAllAlbums() ([]AlbumSummary, error) {
 
 	for rows.Next() {
 		var id int
-		var albumartist, album string
-		if err := rows.Scan(&id, &albumartist, &album); err != nil {
+		var albumartist, album, genre string
+		if err := rows.Scan(&id, &albumartist, &album, &genre); err != nil {
 			return nil, fmt.Errorf("error scanning rows in beets database %s", err)
 		}
 
@@ -107,6 +113,7 @@ func (b *beets) GetAllAlbums() ([]AlbumSummary, error) {
 			ID:     id,
 			Title:  album,
 			Artist: albumartist,
+			Genre:  genre,
 		})
 	}
 
@@ -117,6 +124,79 @@ func (b *beets) GetAllAlbums() ([]AlbumSummary, error) {
 	return albums, nil
 }
 
+// GetAlbumsByAttribute reads the albums that have a flexible attribute set to the given value, either on
+// the album itself or on any of its items
+func (b *beets) GetAlbumsByAttribute(key, value string) ([]AlbumSummary, error) {
+
+	// older beets databases don't have the flexible attribute tables
+	queries := []string{}
+
+	hasAlbumAttrs, albumAttrsErr := b.hasTable("album_attributes")
+	if albumAttrsErr != nil {
+		return nil, albumAttrsErr
+	}
+	if hasAlbumAttrs {
+		queries = append(queries, `SELECT entity_id FROM album_attributes WHERE key = ? AND value = ?`)
+	}
+
+	hasItemAttrs, itemAttrsErr := b.hasTable("item_attributes")
+	if itemAttrsErr != nil {
+		return nil, itemAttrsErr
+	}
+	if hasItemAttrs {
+		queries = append(queries, `SELECT items.album_id FROM item_attributes JOIN items ON items.id = item_attributes.entity_id WHERE item_attributes.key = ? AND item_attributes.value = ?`)
+	}
+
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("beets database %s has no flexible attribute tables", b.dbFile)
+	}
+
+	args := []interface{}{}
+	for range queries {
+		args = append(args, key, value)
+	}
+
+	albums := []AlbumSummary{}
+
+	rows, err := b.db.Query(`SELECT id, albumartist, album, genre FROM albums WHERE id IN (`+strings.Join(queries, " UNION ")+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying albums by attribute from beets database %s", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var albumartist, album, genre string
+		if err := rows.Scan(&id, &albumartist, &album, &genre); err != nil {
+			return nil, fmt.Errorf("error scanning rows in beets database %s", err)
+		}
+
+		albums = append(albums, AlbumSummary{
+			ID:     id,
+			Title:  album,
+			Artist: albumartist,
+			Genre:  genre,
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading albums from beets database %s", err)
+	}
+
+	return albums, nil
+}
+
+// hasTable checks if a table exists in the beets database
+func (b *beets) hasTable(tableName string) (bool, error) {
+	var cnt int
+	err := b.db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, tableName).Scan(&cnt)
+	if err != nil {
+		return false, fmt.Errorf("error querying tables from beets database %s", err)
+	}
+
+	return cnt > 0, nil
+}
+
 // GetAlbum reads a complete set of album data from the beets database
 func (b *beets) GetAlbum(albumID int) (*Album, error) {
 
@@ -137,7 +217,7 @@ func (b *beets) GetAlbum(albumID int) (*Album, error) {
 	}
 
 	if len(tracks) == 0 {
-		return nil, fmt.Errorf("album had no items %s", tracksErr)
+		return nil, fmt.Errorf("album had no items")
 	}
 
 	for _, track := range tracks {
@@ -202,11 +282,39 @@ func New(dbFile string) (Beets, error) {
 		return nil, fmt.Errorf("beets musiclibrary file path is required")
 	}
 
-	db, err := sql.Open("sqlite3", dbFile)
+	// sql.Open is lazy so check the file exists up front rather than failing on the first query
+	info, statErr := os.Stat(dbFile)
+	if statErr != nil {
+		return nil, fmt.Errorf("error opening beets database %s", statErr)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("beets database %s is a directory", dbFile)
+	}
+
+	absPath, absErr := filepath.Abs(dbFile)
+	if absErr != nil {
+		return nil, fmt.Errorf("error opening beets database %s", absErr)
+	}
+
+	// milkdud never writes to the library so open it read only to avoid locking out beets, albums are
+	// read from several goroutines, each on its own connection so sqlite's own serialization isn't needed
+	dsn := url.URL{
+		Scheme:   "file",
+		Path:     absPath,
+		RawQuery: "mode=ro&_mutex=no",
+	}
+	db, err := sql.Open("sqlite3", dsn.String())
 	if err != nil {
 		return nil, fmt.Errorf("error opening beets database %s", err)
 	}
 
+	// reading the schema fails if the file isn't a sqlite database
+	var tableCnt int
+	if err := db.QueryRow(`SELECT count(*) FROM sqlite_master`).Scan(&tableCnt); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s is not a valid beets database: %s", dbFile, err)
+	}
+
 	return &beets{
 		dbFile: dbFile,
 		db:     db,
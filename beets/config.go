@@ -0,0 +1,100 @@
+package beets
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// configFileName is the name of the beets config file in the config directory
+	configFileName = "config.yaml"
+
+	// defaultLibrary is the library database beets uses when the config doesn't set one
+	defaultLibrary = "library.db"
+)
+
+// ConfigDir returns the beets config directory the way beets finds it: $BEETSDIR, then
+// %APPDATA%\beets on Windows, then $XDG_CONFIG_HOME/beets or ~/.config/beets
+func ConfigDir() (string, error) {
+	if dir := os.Getenv("BEETSDIR"); len(dir) > 0 {
+		return expandHome(dir)
+	}
+
+	if runtime.GOOS == "windows" {
+		if appData := os.Getenv("APPDATA"); len(appData) > 0 {
+			return filepath.Join(appData, "beets"), nil
+		}
+	}
+
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); len(xdg) > 0 {
+		return filepath.Join(xdg, "beets"), nil
+	}
+
+	home, homeErr := os.UserHomeDir()
+	if homeErr != nil {
+		return "", fmt.Errorf("error finding beets config directory %s", homeErr)
+	}
+
+	return filepath.Join(home, ".config", "beets"), nil
+}
+
+// FindLibrary resolves the library database from the config.yaml in a beets config directory. A relative
+// library path is relative to the config directory, without a library setting library.db is used.
+func FindLibrary(configDir string) (string, error) {
+	configFile := filepath.Join(configDir, configFileName)
+
+	library := defaultLibrary
+
+	contents, readErr := os.ReadFile(configFile)
+	if readErr != nil && !errors.Is(readErr, fs.ErrNotExist) {
+		return "", fmt.Errorf("error reading beets config %s", readErr)
+	}
+
+	if readErr == nil {
+		config := struct {
+			Library string `yaml:"library"`
+		}{}
+		if yamlErr := yaml.Unmarshal(contents, &config); yamlErr != nil {
+			return "", fmt.Errorf("error parsing beets config %s: %s", configFile, yamlErr)
+		}
+		if len(config.Library) > 0 {
+			library = config.Library
+		}
+	}
+
+	library, expandErr := expandHome(library)
+	if expandErr != nil {
+		return "", expandErr
+	}
+
+	if !filepath.IsAbs(library) {
+		library = filepath.Join(configDir, library)
+	}
+
+	if _, statErr := os.Stat(library); statErr != nil {
+		return "", fmt.Errorf("beets library not found %s", statErr)
+	}
+
+	return library, nil
+}
+
+// expandHome replaces a leading ~ with the home directory like beets does
+func expandHome(p string) (string, error) {
+	if p != "~" && !strings.HasPrefix(p, "~/") && !strings.HasPrefix(p, `~\`) {
+		return p, nil
+	}
+
+	home, homeErr := os.UserHomeDir()
+	if homeErr != nil {
+		return "", fmt.Errorf("error expanding %s %s", p, homeErr)
+	}
+
+	return filepath.Join(home, p[1:]), nil
+}
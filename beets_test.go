@@ -0,0 +1,102 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// benchmarkAlbums is the number of albums in the fixture beets library
+const benchmarkAlbums = 1000
+
+// writeBeetsFixture creates a beets library of albums with 12 tracks each under dir and returns the database path
+func writeBeetsFixture(b *testing.B, dir string) string {
+	dbFile := filepath.Join(dir, "library.db")
+	db, openErr := sql.Open("sqlite3", dbFile)
+	if openErr != nil {
+		b.Fatal(openErr)
+	}
+	defer db.Close()
+
+	// the columns milkdud reads from the beets schema
+	for _, stmt := range []string{
+		`CREATE TABLE albums (id INTEGER PRIMARY KEY, albumartist TEXT, album TEXT, genre TEXT)`,
+		`CREATE TABLE items (id INTEGER PRIMARY KEY, path BLOB, album_id INTEGER, title TEXT, artist TEXT,
+			discogs_albumid TEXT, discogs_artistid TEXT, mb_trackid TEXT, mb_albumid TEXT, mb_artistid TEXT)`,
+		`CREATE INDEX items_album_id ON items (album_id)`,
+	} {
+		if _, execErr := db.Exec(stmt); execErr != nil {
+			b.Fatal(execErr)
+		}
+	}
+
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		b.Fatal(txErr)
+	}
+
+	flac := testFlac(240)
+	for a := 1; a <= benchmarkAlbums; a++ {
+		artist := fmt.Sprintf("Artist %d", a%100)
+		albumDir := filepath.Join(dir, "music", artist, fmt.Sprintf("Album %d", a))
+		if mkdirErr := os.MkdirAll(albumDir, 0755); mkdirErr != nil {
+			b.Fatal(mkdirErr)
+		}
+
+		if _, execErr := tx.Exec(`INSERT INTO albums VALUES (?, ?, ?, ?)`, a, artist, fmt.Sprintf("Album %d", a), "Jazz"); execErr != nil {
+			b.Fatal(execErr)
+		}
+
+		for track := 1; track <= 12; track++ {
+			p := filepath.Join(albumDir, fmt.Sprintf("%02d Track.flac", track))
+			if writeErr := os.WriteFile(p, flac, 0644); writeErr != nil {
+				b.Fatal(writeErr)
+			}
+
+			if _, execErr := tx.Exec(`INSERT INTO items (path, album_id, title, artist, discogs_albumid, discogs_artistid, mb_trackid, mb_albumid, mb_artistid)
+				VALUES (?, ?, ?, ?, '', '', '', '', '')`, []byte(p), a, fmt.Sprintf("Track %d", track), artist); execErr != nil {
+				b.Fatal(execErr)
+			}
+		}
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		b.Fatal(commitErr)
+	}
+
+	return dbFile
+}
+
+func BenchmarkCrawlBeetsDB(b *testing.B) {
+	dir := b.TempDir()
+	dbFile := writeBeetsFixture(b, dir)
+
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				scanResults := make(chan scanResult)
+				go func() {
+					if crawlErr := crawlBeetsDB(dbFile, filepath.Join(dir, "music"), "", "", workers, nil, scanResults); crawlErr != nil {
+						b.Error(crawlErr)
+					}
+					close(scanResults)
+				}()
+
+				albums := 0
+				for result := range scanResults {
+					if result.err != nil {
+						b.Fatal(result.err)
+					}
+					albums = albums + 1
+				}
+				if albums != benchmarkAlbums {
+					b.Fatalf("crawled %d albums, want %d", albums, benchmarkAlbums)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"sort"
+)
+
+// unknownGroup is the group of albums without an artist or genre in beets
+const unknownGroup = "unknown"
+
+// GroupStats are the aggregate stats of a group of albums, ex: all albums of an artist
+type GroupStats struct {
+	AlbumCnt               int64   `json:"album_count"`
+	AccuripAlbumCnt        int64   `json:"accurip_album_count"`
+	AccuripCoveragePercent float64 `json:"accurip_coverage_percent"`
+	TotalBytes             int64   `json:"total_bytes"`
+}
+
+// addToGroup adds a scanned music folder to the stats of its group
+func addToGroup(groups map[string]*GroupStats, key string, folder *MusicFolder) {
+	if len(key) == 0 {
+		key = unknownGroup
+	}
+
+	group, found := groups[key]
+	if !found {
+		group = &GroupStats{}
+		groups[key] = group
+	}
+
+	group.AlbumCnt = group.AlbumCnt + 1
+	if folder.HasAccurip {
+		group.AccuripAlbumCnt = group.AccuripAlbumCnt + 1
+	}
+	group.AccuripCoveragePercent = float64(group.AccuripAlbumCnt) / float64(group.AlbumCnt) * 100
+	group.TotalBytes = group.TotalBytes + folder.TotalBytes
+}
+
+// sortedGroupKeys returns the group names in alphabetical order
+func sortedGroupKeys(groups map[string]*GroupStats) []string {
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultCommentTemplate is the torrent comment used when -comment-template isn't set
+const defaultCommentTemplate = "{{.AccuripCount}} accurip albums{{if .Tags}} ({{.Tags}}){{end}}"
+
+// commentData are the variables available to the torrent comment template. The totals are the scan stats, the
+// torrent only filters like -torrent-exclude and -torrent-only-accurip aren't applied to them.
+type commentData struct {
+	AccuripCount int64  // albums with an accurip log
+	TotalAlbums  int64  // albums counted in the scan stats
+	TotalFiles   int64  // files counted in the scan stats
+	TotalBytes   int64  // size of the files in bytes
+	TotalSize    string // human readable size of the files
+	Date         string // date the torrent was created as YYYY-MM-DD
+	Path         string // scanned library path
+	Tags         string // tags from -g
+}
+
+// parseCommentTemplate parses the torrent comment template, checking it only uses known variables
+func parseCommentTemplate(s string) (*template.Template, error) {
+	tmpl, parseErr := template.New("comment").Parse(s)
+	if parseErr != nil {
+		return nil, fmt.Errorf("invalid comment template: %s", parseErr)
+	}
+
+	// unknown fields are only reported when the template is executed
+	if execErr := tmpl.Execute(io.Discard, commentData{}); execErr != nil {
+		return nil, fmt.Errorf("invalid comment template: %s", execErr)
+	}
+
+	return tmpl, nil
+}
+
+// torrentComment builds the torrent comment from the template and the scan stats
+func torrentComment(tmpl *template.Template, stats Stats, tags string) (string, error) {
+	var b strings.Builder
+
+	execErr := tmpl.Execute(&b, commentData{
+		AccuripCount: stats.AccuripFolderCnt,
+		TotalAlbums:  stats.FolderCnt,
+		TotalFiles:   stats.TotalFiles,
+		TotalBytes:   stats.TotalFileSizeBytes,
+		TotalSize:    stats.TotalFileSize,
+		Date:         time.Now().Format("2006-01-02"),
+		Path:         stats.Path,
+		Tags:         tags,
+	})
+	if execErr != nil {
+		return "", fmt.Errorf("error building torrent comment: %s", execErr)
+	}
+
+	return b.String(), nil
+}
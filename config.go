@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// flagAliases are the flags setting the same value as another flag, setting either on the command line takes
+// precedence over both in the config file
+var flagAliases = map[string]string{"q": "quiet", "quiet": "q"}
+
+// isFlagSet returns true if a flag was set on the command line or in the config file
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// loadConfig sets flag values from a yaml file of flag name to value, flags set on the command line take precedence
+func loadConfig(configFile string) error {
+	contents, readErr := os.ReadFile(configFile)
+	if readErr != nil {
+		return fmt.Errorf("error reading config file: %s", readErr)
+	}
+
+	values := map[string]interface{}{}
+	if yamlErr := yaml.Unmarshal(contents, &values); yamlErr != nil {
+		return fmt.Errorf("error parsing config file %s: %s", configFile, yamlErr)
+	}
+
+	setOnCommandLine := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+		if alias, ok := flagAliases[f.Name]; ok {
+			setOnCommandLine[alias] = true
+		}
+	})
+
+	// sort the keys so errors are reported consistently
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if key == "config" || flag.Lookup(key) == nil {
+			return fmt.Errorf("unknown key in config file %s: %s", configFile, key)
+		}
+
+		if setOnCommandLine[key] {
+			continue
+		}
+
+		if setErr := flag.Set(key, configValue(values[key])); setErr != nil {
+			return fmt.Errorf("invalid value for %s in config file %s: %s", key, configFile, setErr)
+		}
+	}
+
+	return nil
+}
+
+// configValue converts a yaml value to a flag value, sequences are comma seperated like the list flags
+func configValue(v interface{}) string {
+	items, ok := v.([]interface{})
+	if !ok {
+		return fmt.Sprint(v)
+	}
+
+	values := []string{}
+	for _, item := range items {
+		values = append(values, fmt.Sprint(item))
+	}
+
+	return strings.Join(values, ",")
+}
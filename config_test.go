@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	defer func(quiet bool, exclude, formats string) {
+		*flagQuiet, *flagExclude, *flagFormats = quiet, exclude, formats
+	}(*flagQuiet, *flagExclude, *flagFormats)
+
+	// -q is registered by main
+	if flag.Lookup("q") == nil {
+		flag.BoolVar(flagQuiet, "q", false, "shorthand for -quiet")
+	}
+
+	configFile := filepath.Join(t.TempDir(), "milkdud.yaml")
+	config := "quiet: true\nexclude: [\"*/Live/*\", \"*.cue\"]\nformats:\n  - flac\n  - wav\n"
+	if writeErr := os.WriteFile(configFile, []byte(config), 0644); writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	// -q=false on the command line wins over quiet in the config file
+	if setErr := flag.Set("q", "false"); setErr != nil {
+		t.Fatal(setErr)
+	}
+
+	if configErr := loadConfig(configFile); configErr != nil {
+		t.Fatalf("loadConfig() error = %v", configErr)
+	}
+
+	if *flagQuiet {
+		t.Errorf("quiet = true, want the -q=false of the command line")
+	}
+	if *flagExclude != "*/Live/*,*.cue" {
+		t.Errorf("exclude = %q, want */Live/*,*.cue", *flagExclude)
+	}
+	if *flagFormats != "flac,wav" {
+		t.Errorf("formats = %q, want flac,wav", *flagFormats)
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testFlac returns a flac file with just a STREAMINFO block for a track of seconds at 44.1kHz
+func testFlac(seconds int) []byte {
+	sampleRate := uint64(44100)
+	totalSamples := uint64(seconds) * sampleRate
+
+	b := []byte(flacMagic)
+	b = append(b, 0x80|flacStreamInfoType, 0, 0, flacStreamInfoSize)
+
+	si := make([]byte, flacStreamInfoSize)
+	si[10] = byte(sampleRate >> 12)
+	si[11] = byte(sampleRate >> 4)
+	si[12] = byte(sampleRate<<4) | 0x02
+	si[13] = 0xf0 | byte(totalSamples>>32)&0x0f
+	si[14] = byte(totalSamples >> 24)
+	si[15] = byte(totalSamples >> 16)
+	si[16] = byte(totalSamples >> 8)
+	si[17] = byte(totalSamples)
+
+	return append(b, si...)
+}
+
+// testEACLog returns a minimal EAC log with a CTDB TOCID and the given AccurateRip summary
+func testEACLog(tocID, summary string) []byte {
+	return []byte("Exact Audio Copy V1.6 from 23. October 2020\n\n" +
+		"CTDB TOCID: " + tocID + " found\n" +
+		"[CTDB TOCID: " + tocID + "] found.\n\n" +
+		summary + "\n\n==== End of status report ====\n")
+}
+
+// testLibrary is a small library with an accurip album, an album without a log and an ignored folder
+func testLibrary() fstest.MapFS {
+	return fstest.MapFS{
+		"Artist/Album/01 Intro.flac":  {Data: testFlac(60)},
+		"Artist/Album/02 Outro.flac":  {Data: testFlac(120)},
+		"Artist/Album/rip.log":        {Data: testEACLog("abc123-", "All tracks accurately ripped")},
+		"Artist/Album/cover.jpg":      {Data: []byte("jpeg")},
+		"Artist/Album/notes.txt":      {Data: []byte("notes")},
+		"Artist/NoLog/01 Track.flac":  {Data: testFlac(30)},
+		"Artist/Skip/01 Track.flac":   {Data: testFlac(30)},
+		"Artist/.milkdudignore":       {Data: []byte("Skip\n")},
+		"Other/@eaDir/01 Track.flac":  {Data: testFlac(30)},
+		"Other/Single/01 Single.flac": {Data: testFlac(200)},
+	}
+}
+
+// collectResults runs a crawl feeding scanResults and returns the folders and errors it reported
+func collectResults(crawl func(chan<- scanResult)) ([]*MusicFolder, []error) {
+	scanResults := make(chan scanResult)
+	go func() {
+		crawl(scanResults)
+		close(scanResults)
+	}()
+
+	folders := []*MusicFolder{}
+	errs := []error{}
+	for result := range scanResults {
+		if result.err != nil {
+			errs = append(errs, result.err)
+			continue
+		}
+		folders = append(folders, result.folder)
+	}
+
+	sort.Slice(folders, func(i, j int) bool {
+		return folders[i].Path < folders[j].Path
+	})
+
+	return folders, errs
+}
+
+func TestCrawlFolder(t *testing.T) {
+	root := filepath.FromSlash("/crawl-folder")
+
+	mf, crawlErr := crawlFolder(testLibrary(), root, "Artist/Album", nil)
+	if crawlErr != nil {
+		t.Fatalf("crawlFolder() error = %v", crawlErr)
+	}
+
+	if want := filepath.Join(root, "Artist", "Album"); mf.Path != want {
+		t.Errorf("Path = %s, want %s", mf.Path, want)
+	}
+	if mf.FlacCnt != 2 {
+		t.Errorf("FlacCnt = %d, want 2", mf.FlacCnt)
+	}
+	if !mf.HasAccurip || mf.TocID != "abc123-" || mf.AccuripStatus != AccuripConfirmed {
+		t.Errorf("accurip = %v %q %s, want true abc123- %s", mf.HasAccurip, mf.TocID, mf.AccuripStatus, AccuripConfirmed)
+	}
+	if mf.TotalDurationSeconds != 180 {
+		t.Errorf("TotalDurationSeconds = %v, want 180", mf.TotalDurationSeconds)
+	}
+
+	// the art and unknown files aren't included without -i, the confirmed log is
+	names := []string{}
+	for _, file := range mf.Files {
+		names = append(names, file.Name)
+	}
+	if got, want := strings.Join(names, ","), "01 Intro.flac,02 Outro.flac,rip.log"; got != want {
+		t.Errorf("Files = %s, want %s", got, want)
+	}
+	if mf.FileCnt != 3 {
+		t.Errorf("FileCnt = %d, want 3", mf.FileCnt)
+	}
+}
+
+func TestCrawlFolderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		dir  string
+		want string
+	}{
+		{"missing", "Artist/Missing", "directory does not exist"},
+		{"file", "Artist/Album/rip.log", "not a directory"},
+		{"empty", "", "no directory specified"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, crawlErr := crawlFolder(testLibrary(), "/crawl-folder-errors", tt.dir, nil)
+			if crawlErr == nil || !strings.Contains(crawlErr.Error(), tt.want) {
+				t.Errorf("crawlFolder(%q) error = %v, want %q", tt.dir, crawlErr, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrawlFolderM4a(t *testing.T) {
+	defer func(formats map[FileType]bool) { audioFormats = formats }(audioFormats)
+	audioFormats = map[FileType]bool{FileTypeFlac: true, FileTypeM4a: true}
+
+	// a moov box holding the codec of the track
+	m4a := func(codec string) []byte {
+		return append([]byte{0, 0, 0, byte(8 + len(codec))}, "moov"+codec...)
+	}
+
+	mf, crawlErr := crawlFolder(fstest.MapFS{
+		"Album/01 Lossless.m4a": {Data: m4a("alac")},
+		"Album/02 Lossy.m4a":    {Data: m4a("mp4a")},
+		"Album/03 Broken.m4a":   {Data: []byte{0, 0, 0, 4, 'f', 't', 'y', 'p'}},
+	}, "/crawl-folder-m4a", "Album", nil)
+	if crawlErr != nil {
+		t.Fatalf("crawlFolder() error = %v", crawlErr)
+	}
+
+	// only the lossless file is included, every file is counted as a track
+	if mf.AlacCnt != 1 || mf.OtherAudioCnt != 2 || mf.audioFileCnt() != 3 {
+		t.Errorf("AlacCnt = %d OtherAudioCnt = %d, want 1 and 2", mf.AlacCnt, mf.OtherAudioCnt)
+	}
+	if len(mf.Files) != 1 || mf.Files[0].Name != "01 Lossless.m4a" {
+		t.Errorf("Files = %v, want only 01 Lossless.m4a", mf.Files)
+	}
+
+	mf.assess()
+	if want := filepath.Join("/crawl-folder-m4a", "Album", "03 Broken.m4a"); len(mf.InvalidM4aFiles) != 1 || mf.InvalidM4aFiles[0] != want {
+		t.Errorf("InvalidM4aFiles = %v, want %s", mf.InvalidM4aFiles, want)
+	}
+	if mf.Status != AlbumProblem {
+		t.Errorf("Status = %s, want %s", mf.Status, AlbumProblem)
+	}
+}
+
+func TestCrawlFolderMaxFiles(t *testing.T) {
+	defer func(maxFiles int64) { *flagMaxFiles = maxFiles }(*flagMaxFiles)
+	*flagMaxFiles = 5
+
+	// the album holds 5 files, each scan counts them on its own
+	for i := 0; i < 2; i++ {
+		files := &fileCounter{}
+		if _, crawlErr := crawlFolder(testLibrary(), "/crawl-folder-max-files", "Artist/Album", files); crawlErr != nil {
+			t.Fatalf("crawlFolder() of scan %d error = %v", i+1, crawlErr)
+		}
+		if files.n != 5 {
+			t.Errorf("scan %d counted %d files, want 5", i+1, files.n)
+		}
+	}
+
+	// a failed crawl takes back the files it counted so they aren't counted twice when it's retried
+	files := &fileCounter{n: 4}
+	if _, crawlErr := crawlFolder(testLibrary(), "/crawl-folder-max-files", "Artist/Album", files); !isTooManyFiles(crawlErr) {
+		t.Errorf("crawlFolder() error = %v, want too many files", crawlErr)
+	}
+	if files.n != 4 {
+		t.Errorf("counted %d files after the failed crawl, want 4", files.n)
+	}
+}
+
+func TestCrawlFs(t *testing.T) {
+	root := filepath.FromSlash("/crawl-fs")
+
+	folders, errs := collectResults(func(scanResults chan<- scanResult) {
+		if _, walkErr := crawlFs(testLibrary(), root, 2, 0, nil, scanResults); walkErr != nil {
+			t.Errorf("crawlFs() error = %v", walkErr)
+		}
+	})
+
+	// every folder is crawled with its sub folders, except the ignored and junk ones
+	paths := []string{}
+	for _, mf := range folders {
+		rel, _ := filepath.Rel(root, mf.Path)
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	if got, want := strings.Join(paths, ","), "Artist,Artist/Album,Artist/NoLog,Other,Other/Single"; got != want {
+		t.Errorf("folders = %s, want %s", got, want)
+	}
+
+	// the junk folder is reported so it can be counted
+	if len(errs) != 1 || !isJunkDirSkipped(errs[0]) {
+		t.Errorf("errors = %v, want a single junk folder", errs)
+	}
+
+	for _, mf := range folders {
+		if mf.Path == filepath.Join(root, "Artist") && mf.FlacCnt != 3 {
+			t.Errorf("Artist FlacCnt = %d, want 3 without the ignored folder", mf.FlacCnt)
+		}
+	}
+}
+
+func TestCrawlFsMaxDepth(t *testing.T) {
+	defer func(maxDepth int) { *flagMaxDepth = maxDepth }(*flagMaxDepth)
+	*flagMaxDepth = 1
+
+	folders, errs := collectResults(func(scanResults chan<- scanResult) {
+		if _, walkErr := crawlFs(testLibrary(), "/crawl-fs-depth", 1, 0, nil, scanResults); walkErr != nil {
+			t.Errorf("crawlFs() error = %v", walkErr)
+		}
+	})
+
+	if len(folders) != 2 {
+		t.Errorf("crawled %d folders, want the 2 top level ones", len(folders))
+	}
+
+	truncated := 0
+	for _, err := range errs {
+		if _, exceeded := isDepthExceeded(err); exceeded {
+			truncated = truncated + 1
+		}
+	}
+	if truncated != 3 {
+		t.Errorf("%d folders reported as too deep, want 3", truncated)
+	}
+}
+
+func TestCrawlFsSample(t *testing.T) {
+	var candidateCnt int64
+	folders, _ := collectResults(func(scanResults chan<- scanResult) {
+		var walkErr error
+		candidateCnt, walkErr = crawlFs(testLibrary(), "/crawl-fs-sample", 1, 2, nil, scanResults)
+		if walkErr != nil {
+			t.Errorf("crawlFs() error = %v", walkErr)
+		}
+	})
+
+	if candidateCnt != 5 {
+		t.Errorf("candidates = %d, want 5", candidateCnt)
+	}
+	if len(folders) != 2 {
+		t.Errorf("crawled %d folders, want a sample of 2", len(folders))
+	}
+}
+
+// unreadableFS is a MapFS whose listed directories can't be read, like folders without permission
+type unreadableFS struct {
+	fstest.MapFS
+	unreadable map[string]bool
+}
+
+func (uf unreadableFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if uf.unreadable[name] {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrPermission}
+	}
+	return uf.MapFS.ReadDir(name)
+}
+
+func TestCrawlFsUnreadableFolder(t *testing.T) {
+	root := filepath.FromSlash("/crawl-fs-unreadable")
+
+	fsys := testLibrary()
+	fsys["Artist/Locked/01 Track.flac"] = &fstest.MapFile{Data: testFlac(30)}
+
+	folders, errs := collectResults(func(scanResults chan<- scanResult) {
+		if _, walkErr := crawlFs(unreadableFS{fsys, map[string]bool{"Artist/Locked": true}}, root, 2, 0, nil, scanResults); walkErr != nil {
+			t.Errorf("crawlFs() error = %v", walkErr)
+		}
+	})
+
+	// the rest of the tree is still scanned
+	paths := []string{}
+	for _, mf := range folders {
+		rel, _ := filepath.Rel(root, mf.Path)
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	if got, want := strings.Join(paths, ","), "Artist,Artist/Album,Artist/NoLog,Other,Other/Single"; got != want {
+		t.Errorf("folders = %s, want %s", got, want)
+	}
+
+	// the unreadable folder is reported once, the junk folder is reported as skipped
+	folderErrs := []error{}
+	for _, err := range errs {
+		if !isJunkDirSkipped(err) {
+			folderErrs = append(folderErrs, err)
+		}
+	}
+	if len(folderErrs) != 1 || !errors.Is(folderErrs[0], fs.ErrPermission) || !strings.Contains(folderErrs[0].Error(), "Locked") {
+		t.Errorf("errors = %v, want a single permission error for Artist/Locked", folderErrs)
+	}
+
+	// the parent folder doesn't count the unreadable folder's files
+	for _, mf := range folders {
+		if mf.Path == filepath.Join(root, "Artist") && mf.FlacCnt != 3 {
+			t.Errorf("Artist FlacCnt = %d, want 3", mf.FlacCnt)
+		}
+	}
+}
+
+func TestCrawlPathAncestorIgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+
+	files := map[string][]byte{
+		".milkdudignore":                 []byte("Skip\nbonus*.flac\n"),
+		"Artist/.milkdudignore":          []byte("Album/hidden track.flac\n"),
+		"Artist/Album/01 Track.flac":     testFlac(60),
+		"Artist/Album/bonus 1.flac":      testFlac(60),
+		"Artist/Album/hidden track.flac": testFlac(60),
+		"Artist/Skip/01 Track.flac":      testFlac(60),
+	}
+	for name, data := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if mkdirErr := os.MkdirAll(filepath.Dir(p), 0755); mkdirErr != nil {
+			t.Fatal(mkdirErr)
+		}
+		if writeErr := os.WriteFile(p, data, 0644); writeErr != nil {
+			t.Fatal(writeErr)
+		}
+	}
+
+	// the ignore files of the scan path and the artist folder apply to the album crawled on its own
+	mf, crawlErr := crawlPath(root, filepath.Join(root, "Artist", "Album"), nil)
+	if crawlErr != nil {
+		t.Fatalf("crawlPath() error = %v", crawlErr)
+	}
+	if mf.Path != filepath.Join(root, "Artist", "Album") {
+		t.Errorf("Path = %s, want %s", mf.Path, filepath.Join(root, "Artist", "Album"))
+	}
+	if mf.FlacCnt != 1 || mf.Files[0].Name != "01 Track.flac" {
+		t.Errorf("FlacCnt = %d, want only 01 Track.flac", mf.FlacCnt)
+	}
+
+	// a folder an ignore file above it leaves out isn't crawled
+	if _, crawlErr := crawlPath(root, filepath.Join(root, "Artist", "Skip"), nil); !isIgnoredDir(crawlErr) {
+		t.Errorf("crawlPath() error = %v, want an ignored folder", crawlErr)
+	}
+
+	// folders outside root are crawled on their own
+	mf, crawlErr = crawlPath(filepath.Join(root, "Other"), filepath.Join(root, "Artist", "Skip"), nil)
+	if crawlErr != nil || mf.FlacCnt != 1 {
+		t.Errorf("crawlPath() outside root = %v, want the folder crawled on its own", crawlErr)
+	}
+}
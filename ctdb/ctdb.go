@@ -0,0 +1,147 @@
+package ctdb
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	// lookupURL is the CueTools database lookup URL, it finds the entries of a disc by its TOC
+	lookupURL = "http://db.cuetools.net/lookup2.php?version=3&ctdb=1&metadata=none&fuzzy=0&toc=%s"
+
+	// userAgent identifies milkdud to the CueTools database
+	userAgent = "milkdud ( https://github.com/concretelabs/milkdud )"
+
+	// requestInterval is the minimum time between requests so the database isn't hammered
+	requestInterval = time.Second
+)
+
+// tocIDRegexp matches a valid TOCID, which is used as the cache file name
+var tocIDRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// tocRegexp matches a TOC of colon seperated sector offsets
+var tocRegexp = regexp.MustCompile(`^-?\d+(:-?\d+)+$`)
+
+// Result is what the CueTools database knows about a disc
+type Result struct {
+	Found      bool `json:"found"`
+	Confidence int  `json:"confidence"` // confidence of the best matching pressing
+}
+
+// lookupResponse is the subset of the lookup response used by milkdud
+type lookupResponse struct {
+	Entries []struct {
+		Confidence int `xml:"confidence,attr"`
+	} `xml:"entry"`
+}
+
+// CTDB interface for CueTools database access
+type CTDB interface {
+	Lookup(tocID, toc string) (*Result, error)
+}
+
+// ctdb is the implementation of the CTDB interface
+type ctdb struct {
+	cacheDir    string
+	client      *http.Client
+	mu          sync.Mutex
+	lastRequest time.Time
+}
+
+// Lookup looks up a disc by the TOC from its rip log, the response is cached by TOCID
+func (c *ctdb) Lookup(tocID, toc string) (*Result, error) {
+	if !tocIDRegexp.MatchString(tocID) {
+		return nil, fmt.Errorf("invalid TOCID %s", tocID)
+	}
+
+	if !tocRegexp.MatchString(toc) {
+		return nil, fmt.Errorf("invalid TOC %s for TOCID %s", toc, tocID)
+	}
+
+	cacheFile := filepath.Join(c.cacheDir, tocID+".xml")
+
+	contents, readErr := os.ReadFile(cacheFile)
+	if readErr != nil {
+		var fetchErr error
+		contents, fetchErr = c.fetch(fmt.Sprintf(lookupURL, url.QueryEscape(toc)))
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+
+		if writeErr := os.WriteFile(cacheFile, contents, 0644); writeErr != nil {
+			return nil, fmt.Errorf("error caching CueTools database lookup %s", writeErr)
+		}
+	}
+
+	r := lookupResponse{}
+	if xmlErr := xml.Unmarshal(contents, &r); xmlErr != nil {
+		return nil, fmt.Errorf("error parsing CueTools database lookup %s", xmlErr)
+	}
+
+	result := Result{Found: len(r.Entries) > 0}
+	for _, entry := range r.Entries {
+		if entry.Confidence > result.Confidence {
+			result.Confidence = entry.Confidence
+		}
+	}
+
+	return &result, nil
+}
+
+// fetch requests a URL from the CueTools database, waiting to respect the rate limit
+func (c *ctdb) fetch(url string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if wait := requestInterval - time.Since(c.lastRequest); wait > 0 {
+		time.Sleep(wait)
+	}
+	c.lastRequest = time.Now()
+
+	req, reqErr := http.NewRequest(http.MethodGet, url, nil)
+	if reqErr != nil {
+		return nil, fmt.Errorf("error creating CueTools database request %s", reqErr)
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, respErr := c.client.Do(req)
+	if respErr != nil {
+		return nil, fmt.Errorf("error requesting CueTools database lookup %s", respErr)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error requesting CueTools database lookup %s", resp.Status)
+	}
+
+	body, bodyErr := io.ReadAll(resp.Body)
+	if bodyErr != nil {
+		return nil, fmt.Errorf("error reading CueTools database lookup %s", bodyErr)
+	}
+
+	return body, nil
+}
+
+// New creates a new CueTools database client that caches responses in cacheDir
+func New(cacheDir string) (CTDB, error) {
+	if cacheDir == "" {
+		return nil, fmt.Errorf("CueTools database cache directory is required")
+	}
+
+	if mkdirErr := os.MkdirAll(cacheDir, 0755); mkdirErr != nil {
+		return nil, fmt.Errorf("error creating CueTools database cache directory %s", mkdirErr)
+	}
+
+	return &ctdb{
+		cacheDir: cacheDir,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
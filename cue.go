@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// cueSheet is the track layout described by a cue sheet
+type cueSheet struct {
+	files    []string
+	trackCnt int
+}
+
+// parseCue parses the FILE and TRACK entries of a cue sheet
+func parseCue(contents []byte) cueSheet {
+	cs := cueSheet{
+		files: []string{},
+	}
+
+	// strip the UTF-8 byte order mark some rippers write
+	contents = bytes.TrimPrefix(contents, []byte("\xef\xbb\xbf"))
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "FILE":
+			if name := cueFileName(line[len(fields[0]):]); len(name) > 0 {
+				cs.files = append(cs.files, name)
+			}
+
+		case "TRACK":
+			cs.trackCnt = cs.trackCnt + 1
+		}
+	}
+
+	return cs
+}
+
+// cueFileName extracts the file name from the arguments of a FILE entry ex: "01 Track.flac" WAVE
+func cueFileName(args string) string {
+	args = strings.TrimSpace(args)
+
+	if strings.HasPrefix(args, `"`) {
+		if end := strings.Index(args[1:], `"`); end >= 0 {
+			return args[1 : end+1]
+		}
+		return ""
+	}
+
+	// unquoted names can't contain spaces, the last field is the file type
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// AlbumDiff describes how the scanned albums changed since a previous run
+type AlbumDiff struct {
+	Since   string        `json:"since"`
+	Added   []string      `json:"added"`
+	Removed []string      `json:"removed"`
+	Changed []AlbumChange `json:"changed"`
+}
+
+// AlbumChange describes an album whose files changed since a previous run
+type AlbumChange struct {
+	Path               string `json:"path"`
+	PreviousPath       string `json:"previous_path,omitempty"`
+	FileCnt            int64  `json:"file_count"`
+	PreviousFileCnt    int64  `json:"previous_file_count"`
+	TotalBytes         int64  `json:"total_bytes"`
+	PreviousTotalBytes int64  `json:"previous_total_bytes"`
+}
+
+// loadPreviousAlbums loads the albums from a previous detailed stats json output
+func loadPreviousAlbums(fileName string) ([]MusicFolder, error) {
+	contents, readErr := os.ReadFile(fileName)
+	if readErr != nil {
+		return nil, fmt.Errorf("error reading previous run: %s", readErr)
+	}
+
+	type detailed struct {
+		Albums []MusicFolder `json:"albums"`
+	}
+
+	// output from before the envelope was added holds the stats at the top level
+	envelope := struct {
+		SchemaVersion int      `json:"schema_version"`
+		Data          detailed `json:"data"`
+	}{}
+	if jsonErr := json.Unmarshal(contents, &envelope); jsonErr != nil {
+		return nil, fmt.Errorf("error parsing previous run %s: %s", fileName, jsonErr)
+	}
+
+	previous := envelope.Data
+	if envelope.SchemaVersion == 0 {
+		if jsonErr := json.Unmarshal(contents, &previous); jsonErr != nil {
+			return nil, fmt.Errorf("error parsing previous run %s: %s", fileName, jsonErr)
+		}
+	}
+
+	if previous.Albums == nil {
+		return nil, fmt.Errorf("previous run %s has no albums, it must be created with -j -d", fileName)
+	}
+
+	return previous.Albums, nil
+}
+
+// diffAlbums compares albums against a previous run, matching by path and falling back to TOCID for moved albums
+func diffAlbums(since string, previous, current []MusicFolder) *AlbumDiff {
+	diff := AlbumDiff{
+		Since:   since,
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []AlbumChange{},
+	}
+
+	byPath := map[string]int{}
+	for i, album := range previous {
+		byPath[album.Path] = i
+	}
+
+	// index of the matching previous album for each current album
+	matches := make([]int, len(current))
+	matched := map[int]bool{}
+	for j, album := range current {
+		matches[j] = -1
+		if i, found := byPath[album.Path]; found {
+			matches[j] = i
+			matched[i] = true
+		}
+	}
+
+	byTocID := map[string]int{}
+	for i, album := range previous {
+		if _, found := byTocID[album.TocID]; !found && !matched[i] && len(album.TocID) > 0 {
+			byTocID[album.TocID] = i
+		}
+	}
+
+	for j, album := range current {
+		if matches[j] >= 0 || len(album.TocID) == 0 {
+			continue
+		}
+		if i, found := byTocID[album.TocID]; found && !matched[i] {
+			matches[j] = i
+			matched[i] = true
+		}
+	}
+
+	for j, album := range current {
+		if matches[j] < 0 {
+			diff.Added = append(diff.Added, album.Path)
+			continue
+		}
+
+		prev := previous[matches[j]]
+
+		if prev.FileCnt != album.FileCnt || prev.TotalBytes != album.TotalBytes || prev.Path != album.Path {
+			change := AlbumChange{
+				Path:               album.Path,
+				FileCnt:            album.FileCnt,
+				PreviousFileCnt:    prev.FileCnt,
+				TotalBytes:         album.TotalBytes,
+				PreviousTotalBytes: prev.TotalBytes,
+			}
+			if prev.Path != album.Path {
+				change.PreviousPath = prev.Path
+			}
+			diff.Changed = append(diff.Changed, change)
+		}
+	}
+
+	for i, album := range previous {
+		if !matched[i] {
+			diff.Removed = append(diff.Removed, album.Path)
+		}
+	}
+
+	return &diff
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// partialHashSize is the number of bytes read from the start of a file for the cheap partial hash
+const partialHashSize = 64 * 1024
+
+// DuplicateGroup is a set of files with identical contents
+type DuplicateGroup struct {
+	Hash             string   `json:"hash"`
+	Size             int64    `json:"size"`
+	Paths            []string `json:"paths"`
+	ReclaimableBytes int64    `json:"reclaimable_bytes"`
+}
+
+type hashJob struct {
+	path string
+	size int64
+}
+
+type hashResult struct {
+	path string
+	size int64
+	hash string
+	err  error
+}
+
+// findDuplicates finds files with identical contents by comparing size, then a partial hash and finally a full SHA1
+func findDuplicates(files map[string]int64) ([]DuplicateGroup, []error) {
+	errs := []error{}
+
+	// group by size first, files with a unique size can't be duplicates
+	bySize := map[int64][]string{}
+	for p, size := range files {
+		bySize[size] = append(bySize[size], p)
+	}
+
+	candidates := []hashJob{}
+	for size, paths := range bySize {
+		if len(paths) > 1 {
+			for _, p := range paths {
+				candidates = append(candidates, hashJob{p, size})
+			}
+		}
+	}
+
+	// cheap partial hash of the start of each candidate
+	partial := map[string][]hashJob{}
+	for _, r := range hashFiles(candidates, partialHashSize) {
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
+		key := fmt.Sprintf("%d-%s", r.size, r.hash)
+		partial[key] = append(partial[key], hashJob{r.path, r.size})
+	}
+
+	candidates = []hashJob{}
+	for _, jobs := range partial {
+		if len(jobs) > 1 {
+			candidates = append(candidates, jobs...)
+		}
+	}
+
+	// full hash of anything that still collides
+	full := map[string][]hashResult{}
+	for _, r := range hashFiles(candidates, -1) {
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
+		key := fmt.Sprintf("%d-%s", r.size, r.hash)
+		full[key] = append(full[key], r)
+	}
+
+	groups := []DuplicateGroup{}
+	for _, results := range full {
+		if len(results) < 2 {
+			continue
+		}
+
+		group := DuplicateGroup{
+			Hash:  results[0].hash,
+			Size:  results[0].size,
+			Paths: []string{},
+		}
+		for _, r := range results {
+			group.Paths = append(group.Paths, r.path)
+		}
+		sort.Strings(group.Paths)
+		group.ReclaimableBytes = group.Size * int64(len(group.Paths)-1)
+
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Paths[0] < groups[j].Paths[0]
+	})
+
+	return groups, errs
+}
+
+// hashFiles SHA1 hashes files using a pool of workers, limit is the number of bytes to hash or -1 for the whole file
+func hashFiles(jobs []hashJob, limit int64) []hashResult {
+	c := make(chan hashJob)
+	results := make(chan hashResult)
+
+	worker := func(wg *sync.WaitGroup) {
+		for job := range c {
+			hash, err := hashFile(job.path, limit)
+			results <- hashResult{job.path, job.size, hash, err}
+		}
+		wg.Done()
+	}
+
+	go func() {
+		var wg sync.WaitGroup
+		for i := 0; i < runtime.NumCPU(); i++ {
+			wg.Add(1)
+			go worker(&wg)
+		}
+		wg.Wait()
+		close(results)
+	}()
+
+	// allocate
+	go func() {
+		for _, job := range jobs {
+			c <- job
+		}
+		close(c)
+	}()
+
+	hashed := []hashResult{}
+	for r := range results {
+		hashed = append(hashed, r)
+	}
+
+	return hashed
+}
+
+// hashFile returns the hex encoded SHA1 of a file, limit is the number of bytes to hash or -1 for the whole file
+func hashFile(p string, limit int64) (string, error) {
+	f, openErr := os.Open(p)
+	if openErr != nil {
+		return "", fmt.Errorf("error opening %s: %s", p, openErr)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if limit >= 0 {
+		r = io.LimitReader(f, limit)
+	}
+
+	h := sha1.New()
+	if _, copyErr := io.Copy(h, r); copyErr != nil {
+		return "", fmt.Errorf("error hashing %s: %s", p, copyErr)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
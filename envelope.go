@@ -0,0 +1,52 @@
+package main
+
+import (
+	"runtime/debug"
+)
+
+// schemaVersion is the version of the json output structure, bump it when a field is renamed, removed or changes type
+const schemaVersion = 1
+
+// toolVersion is the milkdud version, it can be set at build time with -ldflags "-X main.toolVersion=v1.2.3"
+var toolVersion = ""
+
+// Kinds of data wrapped in an envelope
+const (
+	kindStats         = "stats"
+	kindDetailedStats = "detailed_stats"
+	kindAlbum         = "album"
+	kindAlbums        = "albums"
+	kindTorrent       = "torrent"
+	kindFiles         = "files"
+)
+
+// Envelope wraps all json output so consumers can detect format changes
+type Envelope struct {
+	SchemaVersion int         `json:"schema_version"`
+	ToolVersion   string      `json:"tool_version"`
+	Kind          string      `json:"kind"`
+	Data          interface{} `json:"data"`
+}
+
+// newEnvelope wraps data of the given kind
+func newEnvelope(kind string, data interface{}) Envelope {
+	return Envelope{
+		SchemaVersion: schemaVersion,
+		ToolVersion:   version(),
+		Kind:          kind,
+		Data:          data,
+	}
+}
+
+// version returns the milkdud version set at build time, or the module version when installed with go install
+func version() string {
+	if len(toolVersion) > 0 {
+		return toolVersion
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok && len(info.Main.Version) > 0 {
+		return info.Main.Version
+	}
+
+	return "(devel)"
+}
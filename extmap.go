@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// FileCategory is how a file with a custom extension is handled
+type FileCategory string
+
+const (
+	CategoryAudio  FileCategory = "audio"  // counted as a track and added to the torrent
+	CategoryLog    FileCategory = "log"    // parsed as a rip log
+	CategoryArt    FileCategory = "art"    // added like album art with -i
+	CategoryIgnore FileCategory = "ignore" // left out, also works for the built in types
+)
+
+// builtinFileTypes are the extensions with their own handling, they can only be ignored
+var builtinFileTypes = []FileType{
+	FileTypeFlac, FileTypeM4a, FileTypeWav, FileTypeLog, FileTypeLogGz, FileTypeAccurip, FileTypeJpg, FileTypeJpeg,
+	FileTypePng, FileTypeZip, FileTypeCue, FileTypeM3u, FileTypeBin, FileTypeImg, FileTypeIso,
+}
+
+// defaultFileTypeAliases are the other extensions of the built in types
+var defaultFileTypeAliases = map[FileType]FileType{
+	"jpe": FileTypeJpeg,
+}
+
+// extMap maps extensions to their category, set from -ext-map
+var extMap = map[FileType]FileCategory{}
+
+// fileTypeAliases maps extensions to the built in type they're handled as, the defaults and the ones set from -ext-map
+var fileTypeAliases = defaultFileTypeAliases
+
+// fileTypeOf returns the type of a file from its extension, ignoring case ex: 01.FLAC is a flac file
+func fileTypeOf(name string) FileType {
+	lower := strings.ToLower(name)
+	if strings.HasSuffix(lower, "."+string(FileTypeLogGz)) {
+		return FileTypeLogGz
+	}
+
+	ft := FileType(strings.TrimPrefix(path.Ext(lower), "."))
+	if alias, found := fileTypeAliases[ft]; found {
+		return alias
+	}
+	return ft
+}
+
+// parseExtMap sets the extension map from a comma seperated list of extension:category pairs ex: ape:audio,nfo:log.
+// An extension mapped to a built in type is handled as that type ex: fla:flac.
+func parseExtMap(s string) error {
+	m := map[FileType]FileCategory{}
+	aliases := map[FileType]FileType{}
+	for ext, ft := range defaultFileTypeAliases {
+		aliases[ext] = ft
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+
+		ext, category, found := strings.Cut(pair, ":")
+		ft := FileType(strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), ".")))
+		fc := FileCategory(strings.ToLower(strings.TrimSpace(category)))
+		if !found || len(ft) == 0 {
+			return fmt.Errorf("invalid extension mapping %s, must be extension:category", pair)
+		}
+
+		for _, builtin := range builtinFileTypes {
+			if ft == builtin && fc != CategoryIgnore {
+				return fmt.Errorf("%s is a built in file type, it can only be mapped to ignore", ft)
+			}
+		}
+
+		switch fc {
+		case CategoryAudio, CategoryLog, CategoryArt, CategoryIgnore:
+			m[ft] = fc
+			delete(aliases, ft)
+			continue
+		}
+
+		isBuiltin := false
+		for _, builtin := range builtinFileTypes {
+			isBuiltin = isBuiltin || FileType(fc) == builtin
+		}
+		if !isBuiltin {
+			return fmt.Errorf("invalid category %s for %s, must be audio, log, art, ignore or a built in type", fc, ft)
+		}
+
+		aliases[ft] = FileType(fc)
+		delete(m, ft)
+	}
+
+	extMap = m
+	fileTypeAliases = aliases
+
+	return nil
+}
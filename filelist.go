@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readFileList reads the files listed one per line in fileName, blank lines and lines starting with # are skipped
+func readFileList(fileName string) ([]string, error) {
+	f, openErr := os.Open(fileName)
+	if openErr != nil {
+		return nil, fmt.Errorf("error reading file list: %s", openErr)
+	}
+	defer f.Close()
+
+	paths := []string{}
+	seen := map[string]bool{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p, absErr := filepath.Abs(line)
+		if absErr != nil {
+			return nil, fmt.Errorf("error reading file list %s: %s", fileName, absErr)
+		}
+
+		if !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+
+	if scanErr := scanner.Err(); scanErr != nil {
+		return nil, fmt.Errorf("error reading file list %s: %s", fileName, scanErr)
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no files listed in %s", fileName)
+	}
+
+	return paths, nil
+}
+
+// commonDir returns the deepest folder containing all the paths
+func commonDir(paths []string) string {
+	root := filepath.Dir(paths[0])
+
+	for _, p := range paths[1:] {
+		for root != filepath.Dir(root) && !strings.HasPrefix(p, root+string(filepath.Separator)) {
+			root = filepath.Dir(root)
+		}
+	}
+
+	return root
+}
+
+// commonFolder returns the deepest folder containing all the folders
+func commonFolder(dirs []string) string {
+	root := filepath.Clean(dirs[0])
+
+	for _, dir := range dirs[1:] {
+		dir = filepath.Clean(dir)
+		for root != filepath.Dir(root) && dir != root && !strings.HasPrefix(dir, root+string(filepath.Separator)) {
+			root = filepath.Dir(root)
+		}
+	}
+
+	return root
+}
+
+// crawlFileList builds a music folder for each folder of the listed files without crawling the folders.
+// Every listed file must exist.
+func crawlFileList(paths []string, scanResults chan<- scanResult) error {
+	folders := map[string]*MusicFolder{}
+	order := []string{}
+
+	for _, p := range paths {
+		info, statErr := os.Stat(p)
+		if statErr != nil {
+			return fmt.Errorf("error reading listed file: %s", statErr)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("listed path is a directory: %s", p)
+		}
+
+		dir := filepath.Dir(p)
+		mf, found := folders[dir]
+		if !found {
+			mf = &MusicFolder{
+				Path:          dir,
+				AccuripStatus: AccuripNotVerified,
+				Files:         []MusicFile{},
+			}
+			folders[dir] = mf
+			order = append(order, dir)
+		}
+
+		fsys := throttleScan(os.DirFS(dir))
+		name := info.Name()
+		file := MusicFile{
+			Path:     p,
+			Name:     name,
+			Size:     info.Size(),
+			FileType: fileTypeOf(name),
+		}
+
+		// extensions mapped with -ext-map are handled by their category, listed art is always included
+		switch extMap[file.FileType] {
+		case CategoryIgnore:
+			continue
+
+		case CategoryAudio:
+			mf.OtherAudioCnt = mf.OtherAudioCnt + 1
+
+		case CategoryLog:
+			result, accuripErr := detectAccuripInFile(fsys, name)
+			if accuripErr != nil {
+				return fmt.Errorf("error reading accurip log file %s: %w", p, accuripErr)
+			}
+			mf.addAccurip(result)
+		}
+
+		switch file.FileType {
+		case FileTypeFlac:
+			fi, _ := readFlacInfo(fsys, name)
+			file.DurationSeconds = fi.durationSeconds
+			mf.FlacCnt = mf.FlacCnt + 1
+
+		case FileTypeM4a:
+			mf.AlacCnt = mf.AlacCnt + 1
+
+		case FileTypeWav:
+			file.DurationSeconds, _ = wavDuration(fsys, name)
+			mf.WavCnt = mf.WavCnt + 1
+
+		case FileTypeBin, FileTypeImg, FileTypeIso:
+			file.FileType = FileTypeImage
+			mf.ImageCnt = mf.ImageCnt + 1
+
+		case FileTypeAccurip, FileTypeLog, FileTypeLogGz:
+			result, accuripErr := detectAccuripInFile(fsys, name)
+			if accuripErr != nil {
+				return fmt.Errorf("error reading accurip log file %s: %w", p, accuripErr)
+			}
+			mf.addAccurip(result)
+		}
+
+		mf.TotalBytes = mf.TotalBytes + file.Size
+		mf.TotalDurationSeconds = mf.TotalDurationSeconds + file.DurationSeconds
+		mf.FileCnt = mf.FileCnt + 1
+		mf.Files = append(mf.Files, file)
+	}
+
+	for _, dir := range order {
+		scanResults <- scanResult{
+			folders[dir],
+			nil,
+		}
+	}
+
+	return nil
+}
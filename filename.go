@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxFileNameLength is the longest file name in bytes created from user or folder names, leaving room for an extension
+const maxFileNameLength = 200
+
+// reservedFileNames are the device names Windows doesn't allow as file names, with or without an extension
+var reservedFileNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizeFileName makes a name safe to use as a file name on any OS by replacing path separators,
+// reserved and control characters, trimming trailing dots and spaces and limiting the length
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+
+	// cut on a rune boundary
+	if len(name) > maxFileNameLength {
+		name = name[:maxFileNameLength]
+		for len(name) > 0 && !utf8.ValidString(name) {
+			name = name[:len(name)-1]
+		}
+	}
+
+	// Windows drops trailing dots and spaces
+	name = strings.TrimRight(name, ". ")
+
+	base, _, _ := strings.Cut(name, ".")
+	if reservedFileNames[strings.ToUpper(base)] {
+		name = "_" + name
+	}
+
+	if len(name) == 0 {
+		name = "_"
+	}
+
+	return name
+}
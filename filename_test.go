@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "Music Library", "Music Library"},
+		{"slash", "Jazz/Blues", "Jazz_Blues"},
+		{"backslash", `Jazz\Blues`, "Jazz_Blues"},
+		{"parent folder", "../music", ".._music"},
+		{"colon", "Live: 1975", "Live_ 1975"},
+		{"reserved characters", `a*b?c"d<e>f|g`, "a_b_c_d_e_f_g"},
+		{"control characters", "a\tb\nc", "a_b_c"},
+		{"trailing dots", "Vol. 2...", "Vol. 2"},
+		{"trailing dots and spaces", "Music. . ", "Music"},
+		{"only dots", "...", "_"},
+		{"empty", "", "_"},
+		{"reserved name", "CON", "_CON"},
+		{"reserved name lower case", "nul", "_nul"},
+		{"reserved name with extension", "com1.torrent", "_com1.torrent"},
+		{"reserved name prefix", "CONCERT", "CONCERT"},
+		{"unicode", "Sigur Rós – Ágætis byrjun", "Sigur Rós – Ágætis byrjun"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeFileName(tt.in); got != tt.want {
+				t.Errorf("sanitizeFileName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFileNameTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"ascii", strings.Repeat("a", 300), strings.Repeat("a", maxFileNameLength)},
+		// a 2 byte rune straddling the limit is dropped rather than cut in half
+		{"rune boundary", strings.Repeat("a", maxFileNameLength-1) + "é", strings.Repeat("a", maxFileNameLength-1)},
+		// trailing dots left by the cut are trimmed too
+		{"dots at the cut", strings.Repeat("a", maxFileNameLength-2) + "...b", strings.Repeat("a", maxFileNameLength-2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sanitizeFileName(tt.in)
+			if got != tt.want {
+				t.Errorf("sanitizeFileName() = %q (%d bytes), want %d bytes", got, len(got), len(tt.want))
+			}
+			if len(got) > maxFileNameLength || !utf8.ValidString(got) {
+				t.Errorf("sanitizeFileName() = %d bytes, valid UTF-8 %v", len(got), utf8.ValidString(got))
+			}
+		})
+	}
+}
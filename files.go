@@ -1,6 +1,10 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+
+	"concretelabs/milkdud/musicbrainz"
+)
 
 type FileType string
 
@@ -9,16 +13,53 @@ const cueToolsLookupURL = "http://db.cuetools.net/top.php?tocid=%s"
 
 const (
 	FileTypeFlac    FileType = "flac"
+	FileTypeM4a     FileType = "m4a"
+	FileTypeWav     FileType = "wav"
 	FileTypeLog     FileType = "log"
+	FileTypeLogGz   FileType = "log.gz"
 	FileTypeAccurip FileType = "accurip"
 	FileTypeJpg     FileType = "jpg"
 	FileTypeJpeg    FileType = "jpeg"
+	FileTypePng     FileType = "png"
+	FileTypeZip     FileType = "zip"
+	FileTypeCue     FileType = "cue"
+	FileTypeM3u     FileType = "m3u"
+	FileTypeBin     FileType = "bin"
+	FileTypeImg     FileType = "img"
+	FileTypeIso     FileType = "iso"
+	FileTypeImage   FileType = "image" // raw disc images, recorded for bin, img and iso files
+)
+
+// AccuripStatus is the outcome of the verification recorded in an Accurip log
+type AccuripStatus string
+
+const (
+	AccuripNotVerified AccuripStatus = "not_verified" // no TOCID or the rip didn't match the database
+	AccuripPresent     AccuripStatus = "present"      // the disc is in the database but the rip wasn't confirmed
+	AccuripConfirmed   AccuripStatus = "confirmed"    // the rip was confirmed or verified as accurate
 )
 
+// rank orders the statuses from not verified to confirmed
+func (as AccuripStatus) rank() int {
+	switch as {
+	case AccuripConfirmed:
+		return 2
+	case AccuripPresent:
+		return 1
+	default:
+		return 0
+	}
+}
+
 func (ft FileType) String() string {
 	return string(ft)
 }
 
+// IsAudio returns true for lossless audio file types and extensions mapped to audio with -ext-map
+func (ft FileType) IsAudio() bool {
+	return ft == FileTypeFlac || ft == FileTypeM4a || ft == FileTypeWav || extMap[ft] == CategoryAudio
+}
+
 type MusicLibrary struct {
 	Path       string        `json:"path"`
 	FileCnt    int64         `json:"file_count"`
@@ -28,20 +69,102 @@ type MusicLibrary struct {
 }
 
 type MusicFolder struct {
-	Path       string      `json:"path"`
-	HasAccurip bool        `json:"has_accurip"`
-	TocID      string      `json:"toc_id"`
-	Files      []MusicFile `json:"files"`
-	FileCnt    int64       `json:"file_count"`
-	FlacCnt    int64       `json:"flac_count"`
-	TotalBytes int64       `json:"total_bytes"`
+	Path                 string               `json:"path"`
+	Archive              bool                 `json:"archive,omitempty"` // a zip archive crawled as a folder with -zip-albums, left out of torrents
+	Artist               string               `json:"artist,omitempty"`  // from beets
+	Title                string               `json:"title,omitempty"`   // from beets
+	Genre                string               `json:"genre,omitempty"`   // from beets
+	Status               AlbumStatus          `json:"status"`
+	Issues               []string             `json:"issues,omitempty"` // why the status isn't ok
+	HasAccurip           bool                 `json:"has_accurip"`
+	TocID                string               `json:"toc_id"`
+	CTDBChecked          bool                 `json:"ctdb_checked,omitempty"`    // looked up in the CueTools database (-ctdb-verify)
+	CTDBFound            bool                 `json:"ctdb_found,omitempty"`      // the pressing is in the CueTools database
+	CTDBConfidence       int                  `json:"ctdb_confidence,omitempty"` // confidence of the best matching pressing
+	AccuripStatus        AccuripStatus        `json:"accurip_status"`
+	AccuripConfidence    int                  `json:"accurip_confidence"` // lowest track confidence, 0 if unknown
+	LogSigned            bool                 `json:"log_signed"`         // a log has a checksum line
+	LogChecksumValid     bool                 `json:"log_checksum_valid"` // every signed log matches its checksum
+	Files                []MusicFile          `json:"files"`
+	FileCnt              int64                `json:"file_count"`
+	FlacCnt              int64                `json:"flac_count"`
+	AlacCnt              int64                `json:"alac_count"`
+	WavCnt               int64                `json:"wav_count"`
+	ImageCnt             int64                `json:"image_count,omitempty"`       // raw disc images
+	OtherAudioCnt        int64                `json:"other_audio_count,omitempty"` // audio files of extensions mapped with -ext-map and lossy m4a files
+	TotalBytes           int64                `json:"total_bytes"`
+	ArchiveBytes         int64                `json:"archive_bytes,omitempty"` // uncompressed size of the flac files counted inside zip archives with -scan-zip
+	TotalDurationSeconds float64              `json:"total_duration_seconds"`
+	HasReplayGain        bool                 `json:"has_replay_gain"`                // every flac file has ReplayGain tags
+	InvalidFlacFiles     []string             `json:"invalid_flac_files,omitempty"`   // flac files without a valid STREAMINFO
+	InvalidM4aFiles      []string             `json:"invalid_m4a_files,omitempty"`    // m4a files whose codec couldn't be read
+	CueTrackCnt          int64                `json:"cue_track_count,omitempty"`      // tracks listed in cue sheets
+	CueImage             bool                 `json:"cue_image,omitempty"`            // the cue sheets describe single file disc images
+	MissingCueFiles      []string             `json:"missing_cue_files,omitempty"`    // files referenced by cue sheets that don't exist
+	ExpectedTrackCnt     int64                `json:"expected_track_count,omitempty"` // track count from beets
+	MissingTracks        []string             `json:"missing_tracks,omitempty"`       // tracks in beets but not on disk
+	UntrackedFiles       []string             `json:"untracked_files,omitempty"`      // audio files on disk but not in beets
+	LintWarnings         []string             `json:"lint_warnings,omitempty"`        // log and cue files not named after the album (-lint)
+	Release              *musicbrainz.Release `json:"musicbrainz,omitempty"`
+
+	toc string // TOC from the rip log, used to look the disc up in the CueTools database
 }
 
 type MusicFile struct {
-	Path     string   `json:"path"`
-	Name     string   `json:"name"`
-	Size     int64    `json:"size"`
-	FileType FileType `json:"file_type"`
+	Path            string   `json:"path"`
+	Name            string   `json:"name"`
+	Size            int64    `json:"size"`
+	FileType        FileType `json:"file_type"`
+	DurationSeconds float64  `json:"duration_seconds,omitempty"`
+}
+
+// addAccurip records an accurip log result, keeping the best status and the lowest confidence across logs.
+// A single log with a checksum mismatch marks the folder as invalid.
+func (mf *MusicFolder) addAccurip(result accuripResult) {
+	switch result.checksum {
+	case LogChecksumOK:
+		if !mf.LogSigned {
+			mf.LogChecksumValid = true
+		}
+		mf.LogSigned = true
+	case LogChecksumInvalid:
+		mf.LogSigned = true
+		mf.LogChecksumValid = false
+	}
+
+	if result.status.rank() > mf.AccuripStatus.rank() {
+		mf.AccuripStatus = result.status
+	}
+
+	if !result.verified() {
+		return
+	}
+
+	mf.HasAccurip = true
+	mf.TocID = result.tocID
+	mf.toc = result.toc
+
+	if result.confidence > 0 && (mf.AccuripConfidence == 0 || result.confidence < mf.AccuripConfidence) {
+		mf.AccuripConfidence = result.confidence
+	}
+}
+
+// audioFileCnt returns the number of audio files in the folder
+func (mf MusicFolder) audioFileCnt() int64 {
+	return mf.FlacCnt + mf.AlacCnt + mf.WavCnt + mf.OtherAudioCnt
+}
+
+// trackCnt returns the number of tracks in the folder, a disc image holds all the tracks of its cue sheet
+func (mf MusicFolder) trackCnt() int64 {
+	if mf.CueImage {
+		return mf.CueTrackCnt
+	}
+	return mf.audioFileCnt()
+}
+
+// IsDiscImage returns true for raw disc image file types
+func (ft FileType) IsDiscImage() bool {
+	return ft == FileTypeBin || ft == FileTypeImg || ft == FileTypeIso || ft == FileTypeImage
 }
 
 // ToCID returns the CueTools database lookup URL for the given TOC ID
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// scanFilter holds the -include and -exclude globs
+type scanFilter struct {
+	include []string
+	exclude []string
+}
+
+// junkDirs are the system and trash folders of NAS and desktop shares, they're skipped unless -no-default-excludes
+var junkDirs = []string{
+	"@eaDir", "#recycle", "#snapshot", ".AppleDouble", ".AppleDB", ".Spotlight-V100", ".TemporaryItems", ".Trashes",
+	".Trash", ".Trash-*", ".fseventsd", "$RECYCLE.BIN", "System Volume Information", "lost+found",
+}
+
+// scanFilters is the filter applied while scanning, set from -include and -exclude
+var scanFilters = &scanFilter{}
+
+// parseScanFilter parses comma seperated lists of include and exclude globs
+func parseScanFilter(include, exclude string) (*scanFilter, error) {
+	sf := &scanFilter{}
+
+	for _, list := range []struct {
+		s        string
+		patterns *[]string
+	}{{include, &sf.include}, {exclude, &sf.exclude}} {
+		for _, pattern := range strings.Split(list.s, ",") {
+			pattern = strings.TrimSuffix(strings.TrimSpace(pattern), "/")
+			if len(pattern) == 0 {
+				continue
+			}
+
+			if _, matchErr := path.Match(pattern, ""); matchErr != nil {
+				return nil, fmt.Errorf("invalid pattern %s: %s", pattern, matchErr)
+			}
+
+			*list.patterns = append(*list.patterns, pattern)
+		}
+	}
+
+	return sf, nil
+}
+
+// matchGlob returns true if fp matches pattern. Patterns with a / are matched against the end of the path so they
+// match at any depth (ex: */Live/* matches Artist/Live/Album and Genre/Artist/Live/Album), patterns without one
+// match the file or folder name.
+func matchGlob(pattern, fp string) bool {
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(fp))
+		return matched
+	}
+
+	for suffix := fp; ; {
+		if matched, _ := path.Match(pattern, suffix); matched {
+			return true
+		}
+
+		i := strings.Index(suffix, "/")
+		if i < 0 {
+			return false
+		}
+		suffix = suffix[i+1:]
+	}
+}
+
+// excludedFolder returns true if the folder at fp or one of its parent folders matches an exclude pattern
+func (sf *scanFilter) excludedFolder(fp string) bool {
+	for p := fp; p != "." && p != "/"; p = path.Dir(p) {
+		if sf.excluded(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// excluded returns true if the file or folder at fp matches an exclude pattern, folders are skipped with their contents
+func (sf *scanFilter) excluded(fp string) bool {
+	for _, pattern := range sf.exclude {
+		if matchGlob(pattern, fp) {
+			return true
+		}
+	}
+	return false
+}
+
+// isJunkDir returns true if the folder at fp is a system or trash folder that isn't scanned
+func isJunkDir(fp string) bool {
+	if *flagNoDefaultExcludes {
+		return false
+	}
+
+	for _, pattern := range junkDirs {
+		if matched, _ := path.Match(pattern, path.Base(fp)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFolder returns true if re matches the path of a folder, or its artist or album title in beets mode
+func matchesFolder(re *regexp.Regexp, mf *MusicFolder) bool {
+	return re.MatchString(mf.Path) || (len(mf.Artist) > 0 && re.MatchString(mf.Artist)) || (len(mf.Title) > 0 && re.MatchString(mf.Title))
+}
+
+// folderVerdict is whether a scanned folder is included in the stats, or why it's left out
+type folderVerdict int
+
+const (
+	folderIncluded     folderVerdict = iota
+	folderExcluded                   // a junk folder, a folder deeper than -max-depth or one -exclude matches
+	folderUnmatched                  // a folder -match doesn't match
+	folderSizeFiltered               // an album outside -min-album-size and -max-album-size
+	folderNoAccurip                  // a folder without an accurip log
+)
+
+// folderFilter decides which scanned folders are included in the stats, set from -match, -min-album-size and
+// -max-album-size
+type folderFilter struct {
+	match        *regexp.Regexp
+	minAlbumSize int64
+	maxAlbumSize int64
+}
+
+// accept returns the verdict of a scanned folder. rel is the slash seperated path of the folder below the scan
+// path, it's left empty when the scan already skipped the junk, too deep and excluded folders.
+func (ff *folderFilter) accept(mf *MusicFolder, rel string) folderVerdict {
+	if len(rel) > 0 {
+		if *flagMaxDepth > 0 && folderDepth(rel) > *flagMaxDepth {
+			return folderExcluded
+		}
+
+		// a folder is skipped along with everything below it
+		if scanFilters.excludedFolder(rel) {
+			return folderExcluded
+		}
+		for p := rel; p != "." && p != "/"; p = path.Dir(p) {
+			if isJunkDir(p) {
+				return folderExcluded
+			}
+		}
+	}
+
+	if ff.match != nil && !matchesFolder(ff.match, mf) {
+		return folderUnmatched
+	}
+
+	if mf.trackCnt() > 0 && ((ff.minAlbumSize > 0 && mf.TotalBytes < ff.minAlbumSize) || (ff.maxAlbumSize > 0 && mf.TotalBytes > ff.maxAlbumSize)) {
+		return folderSizeFiltered
+	}
+
+	// folders without a log are only counted when rip logs are ignored or only limit the torrent
+	if !mf.HasAccurip && !*flagIgnoreRipLogs && !*flagTorrentOnlyAccurip && len(*flagFilesFrom) == 0 {
+		return folderNoAccurip
+	}
+
+	return folderIncluded
+}
+
+// included returns true if there are no include patterns, or the file at fp or one of its folders matches one
+func (sf *scanFilter) included(fp string) bool {
+	if len(sf.include) == 0 {
+		return true
+	}
+
+	for p := fp; p != "." && p != "/"; p = path.Dir(p) {
+		for _, pattern := range sf.include {
+			if matchGlob(pattern, p) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		fp      string
+		want    bool
+	}{
+		{"*/Live/*", "Artist/Live/Album", true},
+		{"*/Live/*", "Genre/Artist/Live/Album", true},
+		{"*/Live/*", "Live/Album", false},
+		{"*/Live/*", "Artist/Live", false},
+		{"*/Live", "Artist/Live", true},
+		{"*/Live", "Genre/Artist/Live", true},
+		{"Jazz/*", "Music/Jazz/Album", true},
+		{"*.cue", "Artist/Album/disc.cue", true},
+		{"*.cue", "Artist/Album/disc.flac", false},
+		{"Jazz", "Jazz", true},
+		{"Jazz", "Music/Jazz", true},
+		{"Jazz", "Music/Jazz Fusion", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.fp); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.fp, got, tt.want)
+		}
+	}
+}
+
+func TestScanFilterDepths(t *testing.T) {
+	sf, parseErr := parseScanFilter("Jazz", "*/Live/*,*.cue")
+	if parseErr != nil {
+		t.Fatal(parseErr)
+	}
+
+	tests := []struct {
+		fp       string
+		excluded bool
+		included bool
+	}{
+		{"Artist/Live/Album/01.flac", true, false},
+		{"Genre/Artist/Live/Album/01.flac", true, false},
+		{"Jazz/Artist/Album/01.flac", false, true},
+		{"Music/Jazz/Artist/Album/01.flac", false, true},
+		{"Jazz/Artist/Album/disc.cue", true, true},
+	}
+
+	for _, tt := range tests {
+		if got := sf.excludedFolder(tt.fp); got != tt.excluded {
+			t.Errorf("excludedFolder(%s) = %v, want %v", tt.fp, got, tt.excluded)
+		}
+		if got := sf.included(tt.fp); got != tt.included {
+			t.Errorf("included(%s) = %v, want %v", tt.fp, got, tt.included)
+		}
+	}
+}
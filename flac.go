@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+const (
+	// flacMagic is the marker at the start of every FLAC file
+	flacMagic = "fLaC"
+
+	// flacStreamInfoType is the metadata block type of STREAMINFO
+	flacStreamInfoType = 0
+
+	// flacStreamInfoSize is the length of the STREAMINFO metadata block
+	flacStreamInfoSize = 34
+
+	// flacVorbisCommentType is the metadata block type of VORBIS_COMMENT, where the tags are stored
+	flacVorbisCommentType = 4
+)
+
+// flacInfo is what's read from the metadata blocks of a FLAC file
+type flacInfo struct {
+	durationSeconds float64
+	replayGain      bool // has ReplayGain track or album gain tags
+}
+
+// readFlacInfo reads the metadata blocks of a FLAC file, skipping the audio, for the duration and ReplayGain tags
+func readFlacInfo(fsys fs.FS, p string) (flacInfo, error) {
+	f, openErr := fsys.Open(p)
+	if openErr != nil {
+		return flacInfo{}, openErr
+	}
+	defer f.Close()
+
+	// magic + metadata block header + STREAMINFO
+	header := make([]byte, 4+4+flacStreamInfoSize)
+	if _, readErr := io.ReadFull(f, header); readErr != nil {
+		return flacInfo{}, fmt.Errorf("error reading flac header %s: %s", p, readErr)
+	}
+
+	if !bytes.Equal(header[0:4], []byte(flacMagic)) {
+		return flacInfo{}, fmt.Errorf("not a flac file: %s", p)
+	}
+
+	// STREAMINFO must be the first metadata block
+	if header[4]&0x7f != flacStreamInfoType {
+		return flacInfo{}, fmt.Errorf("missing flac STREAMINFO: %s", p)
+	}
+
+	si := header[8:]
+
+	// 20 bits sample rate, 3 bits channels, 5 bits bits per sample, 36 bits total samples
+	sampleRate := uint64(si[10])<<12 | uint64(si[11])<<4 | uint64(si[12])>>4
+	totalSamples := uint64(si[13]&0x0f)<<32 | uint64(si[14])<<24 | uint64(si[15])<<16 | uint64(si[16])<<8 | uint64(si[17])
+
+	if sampleRate == 0 {
+		return flacInfo{}, fmt.Errorf("invalid flac sample rate: %s", p)
+	}
+
+	// a total of 0 samples means the encoder didn't know the length, only the duration is left out
+	info := flacInfo{}
+	if totalSamples > 0 {
+		info.durationSeconds = float64(totalSamples) / float64(sampleRate)
+	}
+
+	// the high bit of the block type marks the last metadata block
+	last := header[4]&0x80 != 0
+	blockHeader := make([]byte, 4)
+	for !last {
+		if _, readErr := io.ReadFull(f, blockHeader); readErr != nil {
+			return info, fmt.Errorf("error reading flac metadata %s: %s", p, readErr)
+		}
+
+		last = blockHeader[0]&0x80 != 0
+		blockType := blockHeader[0] & 0x7f
+		size := int64(blockHeader[1])<<16 | int64(blockHeader[2])<<8 | int64(blockHeader[3])
+
+		if blockType != flacVorbisCommentType {
+			if _, skipErr := io.CopyN(io.Discard, f, size); skipErr != nil {
+				return info, fmt.Errorf("error reading flac metadata %s: %s", p, skipErr)
+			}
+			continue
+		}
+
+		block := make([]byte, size)
+		if _, readErr := io.ReadFull(f, block); readErr != nil {
+			return info, fmt.Errorf("error reading flac vorbis comment %s: %s", p, readErr)
+		}
+
+		info.replayGain = hasReplayGain(parseVorbisComments(block))
+	}
+
+	return info, nil
+}
+
+// parseVorbisComments returns the KEY=value comments of a VORBIS_COMMENT block, the lengths are little endian
+func parseVorbisComments(block []byte) []string {
+	comments := []string{}
+
+	next := func() ([]byte, bool) {
+		if len(block) < 4 {
+			return nil, false
+		}
+		n := uint64(binary.LittleEndian.Uint32(block[0:4]))
+		block = block[4:]
+		if n > uint64(len(block)) {
+			return nil, false
+		}
+		field := block[:n]
+		block = block[n:]
+		return field, true
+	}
+
+	// vendor string
+	if _, ok := next(); !ok {
+		return comments
+	}
+
+	if len(block) < 4 {
+		return comments
+	}
+	cnt := binary.LittleEndian.Uint32(block[0:4])
+	block = block[4:]
+
+	for i := uint32(0); i < cnt; i++ {
+		comment, ok := next()
+		if !ok {
+			break
+		}
+		comments = append(comments, string(comment))
+	}
+
+	return comments
+}
+
+// hasReplayGain returns true if the comments include a ReplayGain track or album gain
+func hasReplayGain(comments []string) bool {
+	for _, comment := range comments {
+		key, _, _ := strings.Cut(comment, "=")
+		key = strings.ToUpper(key)
+		if key == "REPLAYGAIN_TRACK_GAIN" || key == "REPLAYGAIN_ALBUM_GAIN" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// formatDuration formats seconds as HH:MM:SS
+func formatDuration(seconds float64) string {
+	s := int64(seconds)
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, (s/60)%60, s%60)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+	"testing/fstest"
+)
+
+// testVorbisComment returns a last VORBIS_COMMENT metadata block holding comments
+func testVorbisComment(comments ...string) []byte {
+	block := binary.LittleEndian.AppendUint32(nil, 4)
+	block = append(block, "test"...)
+	block = binary.LittleEndian.AppendUint32(block, uint32(len(comments)))
+	for _, comment := range comments {
+		block = binary.LittleEndian.AppendUint32(block, uint32(len(comment)))
+		block = append(block, comment...)
+	}
+
+	header := []byte{0x80 | flacVorbisCommentType, byte(len(block) >> 16), byte(len(block) >> 8), byte(len(block))}
+	return append(header, block...)
+}
+
+func TestReadFlacInfo(t *testing.T) {
+	// STREAMINFO followed by the tags
+	tagged := func(seconds int) []byte {
+		b := testFlac(seconds)
+		b[4] = flacStreamInfoType
+		return append(b, testVorbisComment("TITLE=Intro", "replaygain_track_gain=-6.50 dB")...)
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		duration   float64
+		replayGain bool
+	}{
+		{"duration", testFlac(60), 60, false},
+		{"tags", tagged(60), 60, true},
+		{"unknown duration", tagged(0), 0, true}, // a total of 0 samples is allowed and means unknown
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fi, readErr := readFlacInfo(fstest.MapFS{"track.flac": {Data: tt.data}}, "track.flac")
+			if readErr != nil {
+				t.Fatalf("readFlacInfo() error = %v", readErr)
+			}
+			if fi.durationSeconds != tt.duration || fi.replayGain != tt.replayGain {
+				t.Errorf("readFlacInfo() = %v %v, want %v %v", fi.durationSeconds, fi.replayGain, tt.duration, tt.replayGain)
+			}
+		})
+	}
+
+	// a sample rate of 0 is invalid
+	invalid := testFlac(60)
+	invalid[8+10], invalid[8+11], invalid[8+12] = 0, 0, 0
+	if _, readErr := readFlacInfo(fstest.MapFS{"track.flac": {Data: invalid}}, "track.flac"); readErr == nil {
+		t.Errorf("readFlacInfo() of a sample rate of 0 error = nil, want an error")
+	}
+}
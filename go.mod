@@ -3,14 +3,31 @@ module concretelabs/milkdud
 go 1.19
 
 require (
+	github.com/anacrolix/missinggo/v2 v2.7.0
 	github.com/anacrolix/torrent v1.49.0
+	github.com/fsnotify/fsnotify v1.6.0
 	github.com/mattn/go-sqlite3 v1.14.16
+	github.com/pkg/sftp v1.13.5
+	github.com/prometheus/client_golang v1.12.2
+	golang.org/x/crypto v0.6.0
+	golang.org/x/image v0.5.0
+	golang.org/x/time v0.3.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/anacrolix/missinggo v1.3.0 // indirect
-	github.com/anacrolix/missinggo/v2 v2.7.0 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/bradfitz/iter v0.0.0-20191230175014-e8f45d346db8 // indirect
+	github.com/cespare/xxhash/v2 v2.1.2 // indirect
+	github.com/golang/protobuf v1.5.2 // indirect
 	github.com/huandu/xstrings v1.3.2 // indirect
+	github.com/kr/fs v0.1.0 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/prometheus/client_model v0.2.0 // indirect
+	github.com/prometheus/common v0.35.0 // indirect
+	github.com/prometheus/procfs v0.7.3 // indirect
 	github.com/stretchr/testify v1.8.2 // indirect
+	golang.org/x/sys v0.5.0 // indirect
+	google.golang.org/protobuf v1.28.0 // indirect
 )
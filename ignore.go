@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// ignoreFileName is the file listing globs of files and folders to leave out of the scan, one per line
+const ignoreFileName = ".milkdudignore"
+
+// ignorer matches paths against the .milkdudignore files of their folder and every parent folder
+type ignorer struct {
+	fsys     fs.FS
+	mu       sync.Mutex
+	patterns map[string][]string // patterns by folder
+}
+
+func newIgnorer(fsys fs.FS) *ignorer {
+	return &ignorer{
+		fsys:     fsys,
+		patterns: map[string][]string{},
+	}
+}
+
+// ignored returns true if fp matches a pattern in its own or any parent folder's ignore file.
+// Patterns are matched against the path relative to the ignore file, patterns without a / also
+// match the file or folder name at any depth.
+func (ig *ignorer) ignored(fp string) (bool, error) {
+	if fp == "." {
+		return false, nil
+	}
+
+	dir := fp
+	for dir != "." {
+		dir = path.Dir(dir)
+
+		patterns, loadErr := ig.load(dir)
+		if loadErr != nil {
+			return false, loadErr
+		}
+
+		rel := fp
+		if dir != "." {
+			rel = strings.TrimPrefix(fp, dir+"/")
+		}
+
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, rel); matched {
+				return true, nil
+			}
+			if !strings.Contains(pattern, "/") {
+				if matched, _ := path.Match(pattern, path.Base(fp)); matched {
+					return true, nil
+				}
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// load reads the patterns of the ignore file in dir, caching them for the next lookup
+func (ig *ignorer) load(dir string) ([]string, error) {
+	ig.mu.Lock()
+	defer ig.mu.Unlock()
+
+	if patterns, found := ig.patterns[dir]; found {
+		return patterns, nil
+	}
+
+	patterns := []string{}
+
+	contents, readErr := fs.ReadFile(ig.fsys, path.Join(dir, ignoreFileName))
+	if readErr != nil && !errors.Is(readErr, fs.ErrNotExist) {
+		return nil, fmt.Errorf("error reading %s: %w", path.Join(dir, ignoreFileName), readErr)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// folder patterns (ex: DVD/) match the folder itself
+		patterns = append(patterns, strings.TrimSuffix(line, "/"))
+	}
+
+	ig.patterns[dir] = patterns
+
+	return patterns, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// lintNames adds a warning for each log or cue file named after neither the album folder nor one of its audio
+// files, trackers often reject uploads that don't follow this
+func (mf *MusicFolder) lintNames(names []string) {
+	folderName := filepath.Base(mf.Path)
+
+	audio := map[string]bool{}
+	for _, file := range mf.Files {
+		if file.FileType.IsAudio() && filepath.Dir(file.Path) == mf.Path {
+			audio[strings.TrimSuffix(file.Name, filepath.Ext(file.Name))] = true
+		}
+	}
+
+	for _, name := range names {
+		kind, base := string(FileTypeLog), name
+		switch {
+		case strings.HasSuffix(strings.ToLower(name), "."+string(FileTypeLogGz)):
+			base = name[:len(name)-len(FileTypeLogGz)-1]
+		case strings.EqualFold(filepath.Ext(name), "."+string(FileTypeCue)):
+			kind, base = string(FileTypeCue), strings.TrimSuffix(name, filepath.Ext(name))
+		default:
+			base = strings.TrimSuffix(name, filepath.Ext(name))
+		}
+
+		if base == folderName || audio[base] {
+			continue
+		}
+
+		mf.LintWarnings = append(mf.LintWarnings, fmt.Sprintf("%s named '%s' but album folder is '%s'", kind, name, folderName))
+	}
+}
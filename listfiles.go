@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"concretelabs/milkdud/torrent"
+)
+
+// ListedFile is a file that would be added to the torrent
+type ListedFile struct {
+	Path        string `json:"path"`
+	TorrentPath string `json:"torrent_path"`
+	Size        int64  `json:"size"`
+}
+
+// torrentFiles returns the scanned files that are added to the torrent, leaving out the files that only count in the stats
+func torrentFiles(fd []fileData, torrentExclude map[string]bool) []fileData {
+	files := []fileData{}
+
+	for _, file := range fd {
+		// excluded files still count in the stats but aren't part of the torrent
+		if hasExtension(file.name, torrentExclude) {
+			continue
+		}
+
+		// disc images are large so they're only added when asked for
+		if file.fileType.IsDiscImage() && !*flagIncludeImages {
+			continue
+		}
+
+		// logs, art and playlists still count in the stats but only the audio files are part of the torrent
+		if *flagFlacOnlyTorrent && !file.fileType.IsAudio() {
+			continue
+		}
+
+		// folders without an accurip log still count in the stats but aren't part of the torrent
+		if *flagTorrentOnlyAccurip && !file.accurip {
+			continue
+		}
+
+		files = append(files, file)
+	}
+
+	return files
+}
+
+// torrentRoot returns the folder the torrent files are relative to and the torrent root folder name. A torrent of a
+// single album is rooted at the album folder and named after it, unless -root-name or -root-from-path is set or the
+// files are added to an existing torrent.
+func torrentRoot(scanPath, album string, albumCnt int64) (string, string) {
+	if albumCnt != 1 || len(album) == 0 || *flagRootFromPath || len(*flagAppendTo) > 0 || isFlagSet("root-name") {
+		return scanPath, *flagRootName
+	}
+
+	absAlbum, absErr := filepath.Abs(album)
+	if absErr != nil {
+		absAlbum = album
+	}
+
+	return album, torrent.RootName(absAlbum, *flagRootName, true)
+}
+
+// printFileList prints the files that would be added to a torrent of root named rootName with their path in the
+// torrent and size
+func printFileList(files []fileData, root, rootName string) {
+	name := torrent.RootName(root, rootName, *flagRootFromPath)
+
+	listed := []ListedFile{}
+	total := int64(0)
+	seen := map[string]bool{}
+	for _, file := range files {
+		p := filepath.Join(file.path, file.name)
+
+		// nested album folders list the same files, the torrent only holds them once
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+
+		torrentPath := name
+		if relPath, relErr := filepath.Rel(root, p); relErr == nil {
+			torrentPath = name + "/" + filepath.ToSlash(relPath)
+		}
+
+		listed = append(listed, ListedFile{p, torrentPath, file.size})
+		total = total + file.size
+	}
+
+	if *flagJsonOutput || *flagNDJSONOutput {
+		b, _ := json.MarshalIndent(newEnvelope(kindFiles, listed), "", "  ")
+		fmt.Println(string(b))
+		return
+	}
+
+	for _, file := range listed {
+		fmt.Println(file.Size, file.TorrentPath)
+	}
+	fmt.Println("Files:", len(listed), "Total size:", byteCountSI(total), fmt.Sprintf("(%d bytes)", total))
+}
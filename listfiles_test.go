@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestTorrentRoot(t *testing.T) {
+	defer func(name string) { *flagRootName = name }(*flagRootName)
+
+	tests := []struct {
+		name     string
+		albumCnt int64
+		wantRoot string
+		wantName string
+	}{
+		{"single album", 1, "/data/FLAC/Artist/Album", "Album"},
+		{"library", 2, "/data/FLAC", "music"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, name := torrentRoot("/data/FLAC", "/data/FLAC/Artist/Album", tt.albumCnt)
+			if root != tt.wantRoot || name != tt.wantName {
+				t.Errorf("torrentRoot() = %s %s, want %s %s", root, name, tt.wantRoot, tt.wantName)
+			}
+		})
+	}
+
+	// a -root-name set by the user names every torrent
+	if setErr := flag.Set("root-name", "library"); setErr != nil {
+		t.Fatal(setErr)
+	}
+	if root, name := torrentRoot("/data/FLAC", "/data/FLAC/Artist/Album", 1); root != "/data/FLAC" || name != "library" {
+		t.Errorf("torrentRoot() with -root-name = %s %s, want /data/FLAC library", root, name)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+// LogChecksum is the outcome of checking the checksum footer of a rip log
+type LogChecksum int
+
+const (
+	LogUnsigned        LogChecksum = iota // the log has no checksum line
+	LogChecksumOK                         // the checksum matches the log contents
+	LogChecksumInvalid                    // the log was edited after it was signed
+)
+
+// eacChecksumKey is the key EAC uses to sign its logs
+var eacChecksumKey, _ = hex.DecodeString("9378716cf13e4265ae55338e940b376184da389e50647726b35f6f341ee3efd9")
+
+// logChecksumRegexp matches the checksum footer EAC appends to a log
+var logChecksumRegexp = regexp.MustCompile(`(?:\r?\n){2}==== Log checksum ([0-9A-Fa-f]{64}) ====`)
+
+// utf16LEBOM is the byte order mark EAC writes at the start of its logs
+var utf16LEBOM = []byte{0xff, 0xfe}
+
+// verifyLogChecksum recomputes the checksum of every signed log in the contents of a log file.
+// A file holding several logs is only valid if all of their checksums match.
+func verifyLogChecksum(contents []byte) LogChecksum {
+	text := decodeLog(contents)
+
+	matches := logChecksumRegexp.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return LogUnsigned
+	}
+
+	start := 0
+	for _, match := range matches {
+		signed := text[start:match[0]]
+		if !strings.EqualFold(eacChecksum(signed), text[match[2]:match[3]]) {
+			return LogChecksumInvalid
+		}
+		start = match[1]
+	}
+
+	return LogChecksumOK
+}
+
+// decodeLog decodes a log file, EAC writes UTF-16 logs but they are often converted to UTF-8
+func decodeLog(contents []byte) string {
+	if !bytes.HasPrefix(contents, utf16LEBOM) {
+		return string(contents)
+	}
+
+	contents = contents[len(utf16LEBOM):]
+	units := make([]uint16, len(contents)/2)
+	for i := range units {
+		units[i] = uint16(contents[2*i]) | uint16(contents[2*i+1])<<8
+	}
+
+	return string(utf16.Decode(units))
+}
+
+// eacChecksum computes the EAC checksum of a log, which is the last block of the UTF-16LE encoded log
+// without line breaks, encrypted with 256 bit block Rijndael in CBC mode with a zero IV
+func eacChecksum(text string) string {
+	text = strings.NewReplacer("\r", "", "\n", "", "\ufeff", "", "\ufffe", "").Replace(text)
+
+	units := utf16.Encode([]rune(text))
+	plaintext := make([]byte, 2*len(units))
+	for i, u := range units {
+		plaintext[2*i] = byte(u)
+		plaintext[2*i+1] = byte(u >> 8)
+	}
+
+	cipher := newRijndael(eacChecksumKey, 8)
+	block := make([]byte, cipher.blockSize())
+	for i := 0; i < len(plaintext); i += len(block) {
+		for j := range block {
+			if i+j < len(plaintext) {
+				block[j] ^= plaintext[i+j]
+			}
+		}
+		cipher.encrypt(block)
+	}
+
+	return strings.ToUpper(hex.EncodeToString(block))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyLogChecksum(t *testing.T) {
+	// the signed log is UTF-16 with CRLF line endings like EAC writes it, the tampered copy has one peak level edited
+	tests := []struct {
+		fileName string
+		want     LogChecksum
+	}{
+		{"eac-1.6-signed.log", LogChecksumOK},
+		{"eac-1.6-tampered.log", LogChecksumInvalid},
+		{"eac-1.6.log", LogUnsigned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			contents, readErr := os.ReadFile(filepath.Join("testdata", "logs", tt.fileName))
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+
+			if got := verifyLogChecksum(contents); got != tt.want {
+				t.Errorf("verifyLogChecksum() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEACChecksum(t *testing.T) {
+	contents, readErr := os.ReadFile(filepath.Join("testdata", "logs", "eac-1.6-signed.log"))
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+
+	// the line breaks are left out of the checksum so a log converted to LF and UTF-8 still matches
+	text := decodeLog(contents)
+	match := logChecksumRegexp.FindStringSubmatchIndex(text)
+	if match == nil {
+		t.Fatal("no checksum line found")
+	}
+
+	// computed with an independent Rijndael-256 CBC implementation over the UTF-16LE text
+	want := "B4401E871E361833265D0B1E01E07019DF409CB0BF727776275D5DE10C3C1271"
+	if got := eacChecksum(text[:match[0]]); got != want {
+		t.Errorf("eacChecksum() = %s, want %s", got, want)
+	}
+
+	converted := []byte(strings.ReplaceAll(text, "\r\n", "\n"))
+	if got := verifyLogChecksum(converted); got != LogChecksumOK {
+		t.Errorf("verifyLogChecksum() of the UTF-8 log = %d, want %d", got, LogChecksumOK)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// logParseWorkers is how many logs of a folder are parsed at the same time
+const logParseWorkers = 4
+
+// logFile is a rip log found while crawling a folder
+type logFile struct {
+	fp       string // path in fsys
+	path     string // real path
+	name     string
+	size     int64
+	modTime  time.Time
+	fileType FileType
+}
+
+// cachedLog is the result of a parsed log, it's only valid while the log keeps its modification time and size
+type cachedLog struct {
+	modTime time.Time
+	size    int64
+	result  accuripResult
+}
+
+var (
+	// logCache holds the parsed logs by path so -watch rescans don't parse unchanged logs again, it's only used
+	// with -watch since a single scan reads every log once
+	logCache   = map[string]cachedLog{}
+	logCacheMu sync.Mutex
+)
+
+// parseLogs detects the accurip results of the logs of a folder on a pool of workers, the results are in the order
+// of the logs
+func parseLogs(fsys fs.FS, logs []logFile) ([]accuripResult, error) {
+	results := make([]accuripResult, len(logs))
+	errs := make([]error, len(logs))
+
+	c := make(chan int)
+	wg := sync.WaitGroup{}
+
+	workers := logParseWorkers
+	if len(logs) < workers {
+		workers = len(logs)
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range c {
+				results[i], errs[i] = parseLog(fsys, logs[i])
+			}
+		}()
+	}
+
+	for i := range logs {
+		c <- i
+	}
+	close(c)
+	wg.Wait()
+
+	for i := range logs {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("error reading accurip log file %s: %w", logs[i].name, errs[i])
+		}
+	}
+
+	return results, nil
+}
+
+// parseLog detects the accurip result of a log, with -watch the cached result is used when the log hasn't changed
+func parseLog(fsys fs.FS, lf logFile) (accuripResult, error) {
+	if !*flagWatch {
+		return detectAccuripInFile(fsys, lf.fp)
+	}
+
+	logCacheMu.Lock()
+	cached, found := logCache[lf.path]
+	logCacheMu.Unlock()
+	if found && cached.modTime.Equal(lf.modTime) && cached.size == lf.size {
+		return cached.result, nil
+	}
+
+	result, detectErr := detectAccuripInFile(fsys, lf.fp)
+	if detectErr != nil {
+		return accuripResult{}, detectErr
+	}
+
+	logCacheMu.Lock()
+	logCache[lf.path] = cachedLog{lf.modTime, lf.size, result}
+	logCacheMu.Unlock()
+
+	return result, nil
+}
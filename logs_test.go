@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestParseLogCache(t *testing.T) {
+	defer func(watch bool) { *flagWatch = watch }(*flagWatch)
+
+	lf := logFile{
+		fp:      "eac-1.0b3.log",
+		path:    "/parse-log-cache/eac-1.0b3.log",
+		name:    "eac-1.0b3.log",
+		size:    1,
+		modTime: time.Unix(1, 0),
+	}
+	logs := os.DirFS(filepath.Join("testdata", "logs"))
+
+	cached := func() bool {
+		logCacheMu.Lock()
+		defer logCacheMu.Unlock()
+		_, found := logCache[lf.path]
+		return found
+	}
+
+	// a single scan reads every log once so nothing is cached
+	*flagWatch = false
+	result, parseErr := parseLog(logs, lf)
+	if parseErr != nil {
+		t.Fatalf("parseLog() error = %v", parseErr)
+	}
+	if result.tocID != "mGBBRsSWAy.ghMgcxi_PWbbB3UQ-" {
+		t.Errorf("tocID = %q, want mGBBRsSWAy.ghMgcxi_PWbbB3UQ-", result.tocID)
+	}
+	if cached() {
+		t.Errorf("log cached without -watch")
+	}
+
+	// with -watch the result is kept for the rescans
+	*flagWatch = true
+	if _, parseErr := parseLog(logs, lf); parseErr != nil {
+		t.Fatalf("parseLog() with -watch error = %v", parseErr)
+	}
+	if !cached() {
+		t.Errorf("log not cached with -watch")
+	}
+
+	// an unchanged log isn't read again
+	lf.fp = "missing.log"
+	if result, parseErr := parseLog(logs, lf); parseErr != nil || result.tocID != "mGBBRsSWAy.ghMgcxi_PWbbB3UQ-" {
+		t.Errorf("parseLog() of an unchanged log = %q, %v, want the cached result", result.tocID, parseErr)
+	}
+}
+
+func TestParseLogsMultiDisc(t *testing.T) {
+	// both discs are confirmed, the log of the second disc is kept along with the first
+	mf, crawlErr := crawlFolder(fstest.MapFS{
+		"Album/CD1/01 Track.flac": {Data: testFlac(60)},
+		"Album/CD1.log":           {Data: testEACLog("disc1-", "All tracks accurately ripped")},
+		"Album/CD2/01 Track.flac": {Data: testFlac(60)},
+		"Album/CD2.log":           {Data: testEACLog("disc2-", "All tracks accurately ripped")},
+	}, "/parse-logs-multi-disc", "Album", nil)
+	if crawlErr != nil {
+		t.Fatalf("crawlFolder() error = %v", crawlErr)
+	}
+
+	logs := []string{}
+	for _, file := range mf.Files {
+		if file.FileType == FileTypeLog {
+			logs = append(logs, file.Name)
+		}
+	}
+	if got, want := strings.Join(logs, ","), "CD1.log,CD2.log"; got != want {
+		t.Errorf("logs = %s, want %s", got, want)
+	}
+	if mf.AccuripStatus != AccuripConfirmed {
+		t.Errorf("AccuripStatus = %s, want %s", mf.AccuripStatus, AccuripConfirmed)
+	}
+}
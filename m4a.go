@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// maxMoovSize is the largest moov box read when looking for the codec
+const maxMoovSize = 64 * 1024 * 1024
+
+// isAlac reports whether an m4a file contains Apple Lossless audio rather than lossy AAC
+// by looking for the alac sample description inside the moov box.
+func isAlac(fsys fs.FS, p string) (bool, error) {
+	f, openErr := fsys.Open(p)
+	if openErr != nil {
+		return false, openErr
+	}
+	defer f.Close()
+
+	header := make([]byte, 8)
+	for {
+		if _, readErr := io.ReadFull(f, header); readErr != nil {
+			if readErr == io.EOF {
+				return false, nil
+			}
+			return false, fmt.Errorf("error reading m4a box %s: %s", p, readErr)
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		boxType := string(header[4:8])
+		headerSize := int64(8)
+
+		// a size of 1 means a 64 bit size follows the box type
+		if size == 1 {
+			large := make([]byte, 8)
+			if _, readErr := io.ReadFull(f, large); readErr != nil {
+				return false, fmt.Errorf("error reading m4a box %s: %s", p, readErr)
+			}
+			size = int64(binary.BigEndian.Uint64(large))
+			headerSize = 16
+		}
+
+		// a size of 0 means the box runs to the end of the file
+		if size == 0 && boxType != "moov" {
+			return false, nil
+		}
+
+		if size != 0 && size < headerSize {
+			return false, fmt.Errorf("invalid m4a box size %s", p)
+		}
+
+		if boxType == "moov" {
+			var r io.Reader = io.LimitReader(f, maxMoovSize)
+			if size != 0 {
+				r = io.LimitReader(f, size-headerSize)
+			}
+
+			moov, readErr := io.ReadAll(r)
+			if readErr != nil {
+				return false, fmt.Errorf("error reading m4a moov box %s: %s", p, readErr)
+			}
+
+			return bytes.Contains(moov, []byte("alac")), nil
+		}
+
+		if _, skipErr := io.CopyN(io.Discard, f, size-headerSize); skipErr != nil {
+			return false, fmt.Errorf("error reading m4a box %s: %s", p, skipErr)
+		}
+	}
+}
@@ -1,67 +1,216 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"concretelabs/milkdud/beets"
+	"concretelabs/milkdud/ctdb"
+	"concretelabs/milkdud/musicbrainz"
+	"concretelabs/milkdud/natsort"
 	"concretelabs/milkdud/torrent"
 )
 
 const (
-
-	// maxDepth is the maximum number of directories to scan before skipping the rest
-	maxDepth = 32
-
 	// default trackers via https://raw.githubusercontent.com/ngosang/trackerslist/master/trackers_best.txt
 	defaultAnnounce = "udp://open.stealth.si:80/announce,udp://tracker.opentrackr.org:1337/announce,udp://tracker.openbittorrent.com:6969/announce"
 )
 
+// exit codes
+const (
+	exitOK           = 0
+	exitScanError    = 2
+	exitTorrentError = 3
+	exitBadArgs      = 4
+)
+
+// error policies of -on-error
+const (
+	onErrorSkip  = "skip"
+	onErrorAbort = "abort"
+)
+
 var (
-	// regular expression used to extract the TOCID from an Accurip log
-	tocIDRegexp = regexp.MustCompile(`.*\[CTDB\sTOCID:\s(.*)\]\sfound.*`)
+	// audioFormats are the audio file types included in the scan
+	audioFormats = map[FileType]bool{FileTypeFlac: true}
+)
+
+var (
+	// regular expression used to extract the TOCID from an Accurip log, the wording after the TOCID
+	// differs between EAC and CUETools versions ("found", "found with", "submitted") so it's ignored
+	// and the whitespace may include line breaks in wrapped logs
+	tocIDRegexp = regexp.MustCompile(`\[CTDB\s+TOCID:\s*([^\]\s]+)\s*\]`)
+
+	// regular expression used to extract the AccurateRip confidence of a track
+	confidenceRegexp = regexp.MustCompile(`(?i)confidence:?\s+(\d+)`)
+
+	// log text (lower case) showing the rip was verified as accurate
+	accuripConfirmedMarkers = []string{"has been confirmed", "accurately ripped", "verified"}
+
+	// log text (lower case) showing the rip could not be verified, these win over the confirmed markers
+	accuripFailedMarkers = []string{"no matching", "no match", "not present in database", "not present in accuraterip database", "could not be verified", "cannot be verified", "not verified"}
+
+	// errJunkDir is reported for a system or trash folder that was skipped
+	errJunkDir = errors.New("skipped junk folder")
+
+	// errIgnoredDir is reported for a folder crawled on its own that -exclude or a .milkdudignore file above it leaves out
+	errIgnoredDir = errors.New("ignored folder")
+
+	// errTooManyFiles aborts a scan that found more files than -max-files allows
+	errTooManyFiles = errors.New("too many files")
+
+	// errAlbumFolderMissing is reported for beets albums whose folder no longer exists
+	errAlbumFolderMissing = errors.New("album folder missing")
+
+	// gzipMagic is the header of gzip compressed files
+	gzipMagic = []byte{0x1f, 0x8b}
 )
 
 var (
-	flagJsonOutput    = flag.Bool("j", false, "json stats")
-	flagCreateTorrent = flag.Bool("t", false, "create torrent")
-	flagTorrentName   = flag.String("n", "milkdud", "torrent filename")
-	flagIgnoreRipLogs = flag.Bool("r", false, "ignore rip logs")
-	flagImportArt     = flag.Bool("i", false, "include album art (jpeg image files) in torrent file")
-	flagAnnounce      = flag.String("a", defaultAnnounce, "comma seperated announce URL(s)")
-	FlagBeetsDBPath   = flag.String("b", "", "path to beets database file ex: musiclibrary.db")
-	FlagDetailedStats = flag.Bool("d", false, "show detailed stats")
-	FlagTorrentTag    = flag.String("g", "", "comma seperated tags for torrent comment ex: foo,bar")
+	flagJsonOutput         = flag.Bool("j", false, "json stats")
+	flagCreateTorrent      = flag.Bool("t", false, "create torrent")
+	flagTorrentName        = flag.String("n", "milkdud", "torrent filename")
+	flagIgnoreRipLogs      = flag.Bool("r", false, "ignore rip logs")
+	flagImportArt          = flag.Bool("i", false, "include album art (jpeg and png image files) in torrent file")
+	flagAnnounce           = flag.String("a", defaultAnnounce, "comma seperated announce URL(s)")
+	FlagBeetsDBPath        = flag.String("b", "", "path to beets database file ex: musiclibrary.db, or auto or a beets config directory to use the library from the beets config")
+	FlagDetailedStats      = flag.Bool("d", false, "show detailed stats")
+	FlagTorrentTag         = flag.String("g", "", "comma seperated tags for torrent comment ex: foo,bar")
+	flagRootName           = flag.String("root-name", "music", "torrent root folder name, a torrent of a single album is named after the album folder unless this is set")
+	flagNDJSONOutput       = flag.Bool("ndjson", false, "stream albums as newline delimited json in the order they finish, unsorted with -w, followed by a stats summary line")
+	flagFailOnError        = flag.Bool("fail-on-error", false, "exit non-zero if any folder failed to scan")
+	flagFindDupes          = flag.Bool("find-dupes", false, "find duplicate flac files across all scanned folders")
+	flagArtMaxDim          = flag.Int("art-max-dimension", 0, "scale included album art down to fit within this many pixels")
+	flagM3u                = flag.Bool("m3u", false, "write an m3u playlist into each album folder that doesn't have one")
+	flagM3uInclude         = flag.Bool("m3u-include", false, "include the m3u playlists in the torrent file")
+	flagMagnetOut          = flag.String("magnet-out", "", "append the magnet URL to this file")
+	flagReadRate           = flag.String("read-rate", "", "limit torrent hashing reads in bytes per second ex: 50M")
+	flagQuiet              = flag.Bool("quiet", false, "suppress all output except errors")
+	flagMusicBrainz        = flag.Bool("musicbrainz", false, "look up release details from MusicBrainz in beets mode")
+	flagScanZip            = flag.Bool("scan-zip", false, "count flac files and detect rip logs inside zip archives (not added to torrents)")
+	flagSince              = flag.String("since", "", "show changes since a previous -j -d json output file")
+	flagFormats            = flag.String("formats", "flac", "comma seperated audio formats to include ex: flac,m4a,wav")
+	flagRequireConfirm     = flag.Bool("require-confirmed", false, "only count rip logs that confirm an accurate rip, not just a disc found in the database")
+	flagTocReport          = flag.Bool("toc-report", false, "print the TOCID and CueTools lookup URL of every accurip album")
+	flagPublic             = flag.Bool("public", false, "create a public torrent that can use DHT and PEX")
+	flagIncludeLogs        = flag.Bool("include-logs", false, "include all rip log files, not only the ones with a detected accurip result")
+	flagDumpTorrent        = flag.String("dump-torrent", "", "print the decoded metainfo of a torrent file, or of the created torrent with -t")
+	flagFollowSymlinks     = flag.Bool("follow-symlinks", false, "follow symlinked directories while scanning")
+	flagManifestSha256     = flag.String("manifest-sha256", "", "write a sha256sum compatible manifest of the torrent files")
+	flagBeetsAttr          = flag.String("beets-attr", "", "only scan beets albums with this flexible attribute ex: seed=1")
+	flagMaxFiles           = flag.Int64("max-files", 5000000, "abort the scan once more than this many files are found, 0 for no limit")
+	flagTorrentExclude     = flag.String("torrent-exclude", "", "comma seperated file extensions left out of the torrent but still counted in the stats ex: m3u,nfo")
+	flagNoDate             = flag.Bool("no-date", false, "omit the creation date from the torrent so the same files produce an identical torrent file")
+	flagCreatedBy          = flag.String("created-by", torrent.DefaultCreatedBy, "tool string recorded in the torrent, empty to omit it")
+	flagServe              = flag.String("serve", "", "serve the scan results as a web page and json api on this address ex: :8080")
+	flagMetrics            = flag.String("metrics", "", "serve prometheus metrics of the scan on this address ex: :9090")
+	flagCommentTemplate    = flag.String("comment-template", defaultCommentTemplate, "go template of the torrent comment, variables: AccuripCount, TotalAlbums, TotalFiles, TotalBytes, TotalSize, Date, Path, Tags")
+	flagRootFromPath       = flag.Bool("root-from-path", false, "name the torrent root folder after the scanned folder ex: /data/FLAC becomes FLAC, instead of -root-name")
+	flagAppendTo           = flag.String("append-to", "", "add the scanned files to this existing torrent, keeping its trackers, comment and settings")
+	flagTorrentOnlyAccurip = flag.Bool("torrent-only-accurip", false, "count every folder in the stats but only add folders with an accurip log to the torrent")
+	flagFilesFrom          = flag.String("files-from", "", "create the torrent from exactly the files listed one per line in this file instead of scanning")
+	flagRequireAccurip     = flag.Int64("require-accurip", 0, "exit with an error if fewer than this many accurip albums are found, before creating the torrent")
+	flagWebSeed            = flag.String("webseed", "", "comma seperated web seed URL(s) serving the torrent files, each file is checked after the torrent is created")
+	flagTop                = flag.Int("top", 0, "list the largest and smallest N albums, included in the json detailed stats (-j -d)")
+	flagTrackersFrom       = flag.String("trackers-from", "", "url or file of a newline seperated tracker list that replaces the announce URL(s), urls are cached for a day")
+	flagLint               = flag.Bool("lint", false, "warn about log and cue files that aren't named after their album folder or audio file")
+	flagFlacOnlyTorrent    = flag.Bool("flac-only-torrent", false, "only add the audio files of the selected formats to the torrent, logs and art still count in the stats")
+	flagNameTemplate       = flag.String("name-template", "", "go template of the torrent file name instead of -n, variables: Artist, Album, TocID (single album torrents), AlbumCount, AccuripCount, TotalFiles, TotalSize, Date, Library, Tags")
+	flagListFiles          = flag.Bool("list-files", false, "print the files that would be added to the torrent with their torrent path and size, then exit without hashing")
+	flagIncludeImages      = flag.Bool("include-images", false, "add raw disc images (bin, img, iso) to the torrent, they always count in the stats")
+	flagHashWorkers        = flag.Int("hash-workers", runtime.NumCPU(), "number of workers hashing torrent pieces, defaults to the number of CPUs")
+	flagManifestHash       = flag.String("manifest-hash", "sha256", "hash of the -manifest-sha256 manifest: md5, sha1, sha256 or sha512, torrent pieces are always sha1")
+	flagSample             = flag.Int("sample", 0, "only scan N random folders and estimate the library totals from them")
+	flagExtMap             = flag.String("ext-map", "", "comma seperated extension:category pairs to handle more file types, categories are audio, log, art, ignore or a built in type to add an alias ex: ape:audio,txt:log,tif:art,fla:flac")
+	flagResume             = flag.Bool("resume", false, "save the scan progress to <n>.scan.checkpoint and the torrent hashing progress to <torrent>.checkpoint, and resume from them when run again after an interruption")
+	flagVerifyPaths        = flag.Bool("verify-paths", false, "check every torrent file still exists with its scanned size before hashing, and report all that don't")
+	flagCTDBVerify         = flag.Bool("ctdb-verify", false, "look up every accurip album in the CueTools database by the TOC in its log, responses are cached")
+	flagWorkers            = flag.Int("w", 1, "number of folders scanned at the same time, raise it for network storage")
+	flagInclude            = flag.String("include", "", "comma seperated globs of the files to scan, a file is scanned if its path, name or a parent folder matches ex: Jazz,*/Live")
+	flagExclude            = flag.String("exclude", "", "comma seperated globs of the files and folders left out of the scan, globs with a / match the end of the path at any depth ex: */Live/*,*.cue")
+	flagMaxDepth           = flag.Int("max-depth", 32, "maximum folder depth below the scan path, deeper folders are skipped and reported, 0 for no limit")
+	flagProgress           = flag.Bool("progress", false, "show a progress bar with the scan rate and an ETA instead of dots, when the output is a terminal")
+	flagPathsFrom          = flag.String("paths-from", "", "scan exactly the folders listed one per line, or NUL seperated, in this file instead of walking the scan path, - reads stdin")
+	flagNoDefaultExcludes  = flag.Bool("no-default-excludes", false, "scan the system and trash folders of NAS and desktop shares, ex: @eaDir, #recycle, .AppleDouble")
+	flagZipAlbums          = flag.Bool("zip-albums", false, "report each zip archive as an album folder of its own, detecting the flac files and rip logs inside (not added to torrents)")
+	flagMinAlbumSize       = flag.String("min-album-size", "", "leave albums smaller than this out of the stats and the torrent ex: 50M")
+	flagMaxAlbumSize       = flag.String("max-album-size", "", "leave albums larger than this out of the stats and the torrent ex: 5G")
+	flagMatch              = flag.String("match", "", "only include albums whose folder path, or beets artist or album, matches this regular expression ex: (?i)miles davis")
+	flagOnError            = flag.String("on-error", onErrorSkip, "what to do when a folder fails to scan: skip reports it and carries on, abort stops the scan")
+	flagScanRate           = flag.String("scan-rate", "", "limit scan reads in bytes per second so a NAS serving media isn't saturated ex: 20M")
+	flagMaxIOPS            = flag.Int64("max-iops", 0, "limit the files and folders opened or listed per second while scanning, 0 for no limit")
+	flagConfig             = flag.String("config", "", "yaml file of flag defaults ex: milkdud.yaml")
+	flagWatch              = flag.Bool("watch", false, "keep watching the path and rescan folders as they change")
 )
 
 type Stats struct {
-	Path                  string `json:"path"`
-	FolderCnt             int64  `json:"folder_count"`
-	AccuripFolderCnt      int64  `json:"accurip_folder_count"`
-	FoldersScanned        int64  `json:"folders_scanned"`
-	TotalFileSize         string `json:"total_file_size"`
-	TotalFileSizeBytes    int64  `json:"total_file_size_bytes"`
-	TotalFiles            int64  `json:"total_files"`
-	TotalFlacFiles        int64  `json:"total_flac_files"`
-	AverageAlbumSize      string `json:"average_album_size"`
-	AverageAlbumSizeBytes int64  `json:"average_album_size_bytes"`
-	MagnetURL             string `json:"magnet_url,omitempty"`
-	TorrentFileName       string `json:"torrent_file_name,omitempty"`
-	Errors                int    `json:"errors"`
+	Path                      string             `json:"path"`
+	Paths                     []string           `json:"paths,omitempty"` // the scanned paths when there are several, path is their common folder
+	FolderCnt                 int64              `json:"folder_count"`
+	AccuripFolderCnt          int64              `json:"accurip_folder_count"`
+	FoldersScanned            int64              `json:"folders_scanned"`
+	TotalFileSize             string             `json:"total_file_size"`
+	TotalFileSizeBytes        int64              `json:"total_file_size_bytes"`
+	TotalFiles                int64              `json:"total_files"`
+	TotalFlacFiles            int64              `json:"total_flac_files"`
+	TotalAlacFiles            int64              `json:"total_alac_files"`
+	TotalWavFiles             int64              `json:"total_wav_files"`
+	TotalImageFiles           int64              `json:"total_image_files"`
+	AverageAlbumSize          string             `json:"average_album_size"`
+	AverageAlbumSizeBytes     int64              `json:"average_album_size_bytes"`
+	AccuripCoveragePercent    float64            `json:"accurip_coverage_percent"`
+	ReplayGainFolderCnt       int64              `json:"replay_gain_folder_count"`
+	ReplayGainCoveragePercent float64            `json:"replay_gain_coverage_percent"`
+	TotalDuration             string             `json:"total_duration"`
+	TotalDurationSeconds      float64            `json:"total_duration_seconds"`
+	MagnetURL                 string             `json:"magnet_url,omitempty"`
+	TorrentFileName           string             `json:"torrent_file_name,omitempty"`
+	BytesByType               map[FileType]int64 `json:"bytes_by_type"`
+	Errors                    int                `json:"errors"`
+	Duplicates                []DuplicateGroup   `json:"duplicates,omitempty"`
+	ReclaimableBytes          int64              `json:"reclaimable_bytes,omitempty"`
+	IncompleteAlbums          []string           `json:"incomplete_albums,omitempty"`
+	LogChecksumMismatches     []string           `json:"log_checksum_mismatches,omitempty"`
+	CTDBNotFound              []string           `json:"ctdb_not_found,omitempty"` // accurip albums not in the CueTools database (-ctdb-verify)
+	LintWarningCnt            int64              `json:"lint_warning_count,omitempty"`
+	WarningAlbumCnt           int64              `json:"warning_album_count"`
+	ProblemAlbumCnt           int64              `json:"problem_album_count"`
+	DepthTruncatedCnt         int64              `json:"depth_truncated_count"`
+	JunkDirCnt                int64              `json:"junk_dir_count"`            // system and trash folders skipped, ex: @eaDir
+	UnmatchedCnt              int64              `json:"unmatched_count,omitempty"` // folders left out by -match
+	SizeFiltered              []string           `json:"size_filtered,omitempty"`   // albums outside -min-album-size and -max-album-size
+	Diff                      *AlbumDiff         `json:"diff,omitempty"`
+	Sample                    *SampleEstimate    `json:"sample,omitempty"` // the counts above are for the sampled folders only
+	PathProblems              []PathProblem      `json:"path_problems,omitempty"`
 }
 
 type DetailedStats struct {
 	Stats
-	Albums         []MusicFolder `json:"albums"`
-	SkippedFolders []string      `json:"skipped_folders"`
-	Errors         []error       `json:"errors"`
+	Albums         []MusicFolder          `json:"albums"`
+	SkippedFolders []string               `json:"skipped_folders"`
+	Errors         []error                `json:"errors"`
+	ByArtist       map[string]*GroupStats `json:"by_artist,omitempty"` // beets mode only
+	ByGenre        map[string]*GroupStats `json:"by_genre,omitempty"`  // beets mode only
+	LargestAlbums  []AlbumSize            `json:"largest_albums,omitempty"`
+	SmallestAlbums []AlbumSize            `json:"smallest_albums,omitempty"`
+	DepthTruncated []string               `json:"depth_truncated"` // directories not scanned for exceeding the max depth
 }
 
 type scanResult struct {
@@ -69,57 +218,518 @@ type scanResult struct {
 	err    error
 }
 
-type fileData struct {
+// depthExceededError is reported for a directory that wasn't scanned because it exceeded the max depth
+type depthExceededError struct {
 	path string
-	name string
-	size int64
+}
+
+func (e depthExceededError) Error() string {
+	return fmt.Sprintf("skipping %s, exceeded max depth of %d directories", e.path, *flagMaxDepth)
+}
+
+// fileCounter counts the files found by a scan for -max-files, each file is counted when its own folder is crawled
+type fileCounter struct {
+	n int64
+}
+
+// add counts n more files and returns the total so far, a nil counter counts nothing
+func (fc *fileCounter) add(n int64) int64 {
+	if fc == nil {
+		return 0
+	}
+	return atomic.AddInt64(&fc.n, n)
+}
+
+// accuripResult is what was detected in an Accurip log file
+type accuripResult struct {
+	tocID      string
+	status     AccuripStatus
+	confidence int
+	checksum   LogChecksum
+	toc        string // colon seperated track start sectors and lead-out, empty if the log has no TOC table
+}
+
+// verified returns true if the result counts as an accurip rip
+func (r accuripResult) verified() bool {
+	if len(r.tocID) == 0 {
+		return false
+	}
+
+	switch r.status {
+	case AccuripConfirmed:
+		return true
+	case AccuripPresent:
+		return !*flagRequireConfirm
+	default:
+		return false
+	}
+}
+
+type fileData struct {
+	path     string
+	name     string
+	size     int64
+	fileType FileType
+	accurip  bool // the folder of the file has an accurip log
+}
+
+// addFolder adds an included music folder to the aggregate stats
+func (s *Stats) addFolder(folder *MusicFolder) {
+	s.updateFolder(folder, 1)
+}
+
+// removeFolder removes a previously added music folder from the aggregate stats
+func (s *Stats) removeFolder(folder *MusicFolder) {
+	s.updateFolder(folder, -1)
+}
+
+// updateFolder adds (sign 1) or removes (sign -1) a music folder from the aggregate stats
+func (s *Stats) updateFolder(folder *MusicFolder, sign int64) {
+	if folder.HasAccurip {
+		s.AccuripFolderCnt = s.AccuripFolderCnt + sign
+	}
+	if folder.HasReplayGain {
+		s.ReplayGainFolderCnt = s.ReplayGainFolderCnt + sign
+	}
+	s.FolderCnt = s.FolderCnt + sign
+	switch folder.Status {
+	case AlbumWarning:
+		s.WarningAlbumCnt = s.WarningAlbumCnt + sign
+	case AlbumProblem:
+		s.ProblemAlbumCnt = s.ProblemAlbumCnt + sign
+	}
+	if s.BytesByType == nil {
+		s.BytesByType = map[FileType]int64{}
+	}
+	for _, file := range folder.Files {
+		s.BytesByType[file.FileType] = s.BytesByType[file.FileType] + sign*file.Size
+	}
+	s.TotalFileSizeBytes = s.TotalFileSizeBytes + sign*folder.TotalBytes
+	s.TotalFiles = s.TotalFiles + sign*folder.FileCnt
+	s.TotalFlacFiles = s.TotalFlacFiles + sign*folder.FlacCnt
+	s.TotalAlacFiles = s.TotalAlacFiles + sign*folder.AlacCnt
+	s.TotalWavFiles = s.TotalWavFiles + sign*folder.WavCnt
+	s.TotalImageFiles = s.TotalImageFiles + sign*folder.ImageCnt
+	s.TotalDurationSeconds = s.TotalDurationSeconds + float64(sign)*folder.TotalDurationSeconds
+}
+
+// summarize computes the averages, coverage and human readable sizes once the folders have been added
+func (s *Stats) summarize() {
+	s.TotalFileSize = byteCountSI(s.TotalFileSizeBytes)
+	s.AverageAlbumSizeBytes = 0
+	s.AccuripCoveragePercent = 0
+	s.ReplayGainCoveragePercent = 0
+	if s.FolderCnt > 0 {
+		s.AverageAlbumSizeBytes = s.TotalFileSizeBytes / s.FolderCnt
+		s.AccuripCoveragePercent = float64(s.AccuripFolderCnt) / float64(s.FolderCnt) * 100
+		s.ReplayGainCoveragePercent = float64(s.ReplayGainFolderCnt) / float64(s.FolderCnt) * 100
+	}
+	s.AverageAlbumSize = byteCountSI(s.AverageAlbumSizeBytes)
+	s.TotalDuration = formatDuration(s.TotalDurationSeconds)
 }
 
 func main() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
 		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "Exit codes:\n")
+		fmt.Fprintf(os.Stderr, "  %d  success\n", exitOK)
+		fmt.Fprintf(os.Stderr, "  %d  scan error (or folder errors with -fail-on-error or -on-error abort, or too few accurip albums with -require-accurip)\n", exitScanError)
+		fmt.Fprintf(os.Stderr, "  %d  torrent creation failure\n", exitTorrentError)
+		fmt.Fprintf(os.Stderr, "  %d  bad arguments\n", exitBadArgs)
 	}
 
+	flag.BoolVar(flagQuiet, "q", false, "shorthand for -quiet")
 	flag.Parse()
 
 	if len(os.Args) == 1 {
 		flag.Usage()
-		os.Exit(1)
+		os.Exit(exitBadArgs)
+	}
+
+	if len(*flagConfig) > 0 {
+		configErr := loadConfig(*flagConfig)
+		if configErr != nil {
+			fmt.Fprintln(os.Stderr, configErr)
+			os.Exit(exitBadArgs)
+		}
+	}
+
+	// -b auto or a beets config directory finds the library database the way beets does
+	if len(*FlagBeetsDBPath) > 0 {
+		libraryErr := resolveBeetsLibrary()
+		if libraryErr != nil {
+			fmt.Fprintln(os.Stderr, libraryErr)
+			os.Exit(exitBadArgs)
+		}
+	}
+
+	// appending to a torrent always creates it
+	if len(*flagAppendTo) > 0 {
+		*flagCreateTorrent = true
+	}
+
+	// the paths are the last arguments, several libraries are scanned as one under the folder they have in common
+	scanPaths := flag.Args()
+	if len(scanPaths) == 0 {
+		scanPaths = []string{os.Args[len(os.Args)-1]}
+	}
+	scanPath := scanPaths[0]
+	if len(scanPaths) > 1 {
+		if flagsErr := checkMultiPathFlags(scanPaths); flagsErr != nil {
+			fmt.Fprintln(os.Stderr, flagsErr)
+			os.Exit(exitBadArgs)
+		}
+		scanPath = commonFolder(scanPaths)
+	}
+
+	// only dump an existing torrent when not creating one
+	if len(*flagDumpTorrent) > 0 && !*flagCreateTorrent {
+		dumpErr := dumpTorrent(*flagDumpTorrent)
+		if dumpErr != nil {
+			fmt.Fprintln(os.Stderr, dumpErr)
+			os.Exit(exitTorrentError)
+		}
+		os.Exit(exitOK)
+	}
+
+	// only log human readable output when not writing json or quiet
+	logOutput := !*flagJsonOutput && !*flagNDJSONOutput && !*flagQuiet
+
+	readRate, readRateErr := parseByteSize(*flagReadRate)
+	if readRateErr != nil {
+		fmt.Fprintln(os.Stderr, "invalid read rate:", readRateErr)
+		os.Exit(exitBadArgs)
+	}
+
+	if *flagOnError != onErrorSkip && *flagOnError != onErrorAbort {
+		fmt.Fprintln(os.Stderr, "invalid on-error: must be skip or abort")
+		os.Exit(exitBadArgs)
+	}
+
+	folderFilters := &folderFilter{}
+	if len(*flagMatch) > 0 {
+		var matchErr error
+		folderFilters.match, matchErr = regexp.Compile(*flagMatch)
+		if matchErr != nil {
+			fmt.Fprintln(os.Stderr, "invalid match:", matchErr)
+			os.Exit(exitBadArgs)
+		}
+	}
+
+	var minAlbumSizeErr, maxAlbumSizeErr error
+	folderFilters.minAlbumSize, minAlbumSizeErr = parseByteSize(*flagMinAlbumSize)
+	if minAlbumSizeErr != nil {
+		fmt.Fprintln(os.Stderr, "invalid min album size:", minAlbumSizeErr)
+		os.Exit(exitBadArgs)
+	}
+
+	folderFilters.maxAlbumSize, maxAlbumSizeErr = parseByteSize(*flagMaxAlbumSize)
+	if maxAlbumSizeErr != nil {
+		fmt.Fprintln(os.Stderr, "invalid max album size:", maxAlbumSizeErr)
+		os.Exit(exitBadArgs)
+	}
+
+	if folderFilters.maxAlbumSize > 0 && folderFilters.maxAlbumSize < folderFilters.minAlbumSize {
+		fmt.Fprintln(os.Stderr, "invalid max album size: must not be smaller than the min album size")
+		os.Exit(exitBadArgs)
+	}
+
+	scanRate, scanRateErr := parseByteSize(*flagScanRate)
+	if scanRateErr != nil {
+		fmt.Fprintln(os.Stderr, "invalid scan rate:", scanRateErr)
+		os.Exit(exitBadArgs)
+	}
+
+	if *flagMaxIOPS < 0 {
+		fmt.Fprintln(os.Stderr, "invalid max iops: must be 0 or more")
+		os.Exit(exitBadArgs)
+	}
+
+	scanBytesLimiter = newScanLimiter(scanRate)
+	scanOpsLimiter = newScanLimiter(*flagMaxIOPS)
+
+	if *flagHashWorkers < 1 {
+		fmt.Fprintln(os.Stderr, "invalid hash workers: must be at least 1")
+		os.Exit(exitBadArgs)
+	}
+
+	manifestHash, manifestHashErr := torrent.ManifestHash(*flagManifestHash)
+	if manifestHashErr != nil {
+		fmt.Fprintln(os.Stderr, manifestHashErr)
+		os.Exit(exitBadArgs)
+	}
+
+	formatsErr := parseFormats(*flagFormats)
+	if formatsErr != nil {
+		fmt.Fprintln(os.Stderr, formatsErr)
+		os.Exit(exitBadArgs)
+	}
+
+	var filterErr error
+	scanFilters, filterErr = parseScanFilter(*flagInclude, *flagExclude)
+	if filterErr != nil {
+		fmt.Fprintln(os.Stderr, filterErr)
+		os.Exit(exitBadArgs)
+	}
+
+	// after the formats, which only take the built in audio types
+	extMapErr := parseExtMap(*flagExtMap)
+	if extMapErr != nil {
+		fmt.Fprintln(os.Stderr, extMapErr)
+		os.Exit(exitBadArgs)
+	}
+
+	beetsAttrKey, beetsAttrValue, beetsAttrErr := parseBeetsAttr(*flagBeetsAttr)
+	if beetsAttrErr != nil {
+		fmt.Fprintln(os.Stderr, beetsAttrErr)
+		os.Exit(exitBadArgs)
+	}
+
+	commentTemplate, commentTemplateErr := parseCommentTemplate(*flagCommentTemplate)
+	if commentTemplateErr != nil {
+		fmt.Fprintln(os.Stderr, commentTemplateErr)
+		os.Exit(exitBadArgs)
+	}
+
+	nameTemplate, nameTemplateErr := parseNameTemplate(*flagNameTemplate)
+	if nameTemplateErr != nil {
+		fmt.Fprintln(os.Stderr, nameTemplateErr)
+		os.Exit(exitBadArgs)
+	}
+
+	torrentExclude := parseExtensions(*flagTorrentExclude)
+
+	var metrics *scanMetrics
+	if len(*flagMetrics) > 0 {
+		var metricsErr error
+		metrics, metricsErr = startMetrics(*flagMetrics)
+		if metricsErr != nil {
+			fmt.Fprintln(os.Stderr, metricsErr)
+			os.Exit(exitBadArgs)
+		}
+	}
+
+	// load the previous run before doing any work
+	var previousAlbums []MusicFolder
+	if len(*flagSince) > 0 {
+		var sinceErr error
+		previousAlbums, sinceErr = loadPreviousAlbums(*flagSince)
+		if sinceErr != nil {
+			fmt.Fprintln(os.Stderr, sinceErr)
+			os.Exit(exitBadArgs)
+		}
+	}
+
+	// validate the announce URLs before doing any work
+	announce, announceErr := parseAnnounce(*flagAnnounce)
+	if announceErr != nil {
+		fmt.Fprintln(os.Stderr, announceErr)
+		os.Exit(exitBadArgs)
+	}
+
+	// a tracker list replaces the announce URLs
+	if len(*flagTrackersFrom) > 0 {
+		var trackersErr error
+		announce, trackersErr = loadTrackers(*flagTrackersFrom)
+		if trackersErr != nil {
+			fmt.Fprintln(os.Stderr, trackersErr)
+			os.Exit(exitBadArgs)
+		}
+	}
+
+	webSeeds, webSeedsErr := parseWebSeeds(*flagWebSeed)
+	if webSeedsErr != nil {
+		fmt.Fprintln(os.Stderr, webSeedsErr)
+		os.Exit(exitBadArgs)
+	}
+
+	if *flagPublic && *flagCreateTorrent && len(announce) == 0 {
+		fmt.Fprintln(os.Stderr, "warning: creating a public torrent without trackers, peers can only be found via DHT")
+	}
+
+	// the torrent root of listed files is the folder they have in common
+	var listedFiles []string
+	if len(*flagFilesFrom) > 0 {
+		var listErr error
+		listedFiles, listErr = readFileList(*flagFilesFrom)
+		if listErr != nil {
+			fmt.Fprintln(os.Stderr, listErr)
+			os.Exit(exitBadArgs)
+		}
+		scanPath = commonDir(listedFiles)
+	}
+
+	// listed folders are crawled without walking the scan path, the torrent root is the folder they have in common
+	var listedFolders []string
+	if len(*flagPathsFrom) > 0 {
+		if len(*flagFilesFrom) > 0 || len(*FlagBeetsDBPath) > 0 {
+			fmt.Fprintln(os.Stderr, "-paths-from can't be used with -files-from or -b")
+			os.Exit(exitBadArgs)
+		}
+
+		var listErr error
+		listedFolders, listErr = readPathList(*flagPathsFrom)
+		if listErr != nil {
+			fmt.Fprintln(os.Stderr, listErr)
+			os.Exit(exitBadArgs)
+		}
+		scanPath = commonFolder(listedFolders)
+	}
+
+	// a remote library is read over SFTP or WebDAV, folders are reported by their path on the server
+	scanTarget := scanPath
+	var remoteFS fs.FS
+	if isRemoteTarget(scanPath) && len(*FlagBeetsDBPath) == 0 && len(*flagFilesFrom) == 0 && len(*flagPathsFrom) == 0 {
+		if flagsErr := checkRemoteFlags(); flagsErr != nil {
+			fmt.Fprintln(os.Stderr, flagsErr)
+			os.Exit(exitBadArgs)
+		}
+
+		scanTarget = redactRemoteTarget(scanPath)
+
+		var remoteErr error
+		remoteFS, scanPath, remoteErr = openRemote(scanPath)
+		if remoteErr != nil {
+			fmt.Fprintln(os.Stderr, remoteErr)
+			os.Exit(exitScanError)
+		}
+	}
+
+	if *flagMaxDepth < 0 {
+		fmt.Fprintln(os.Stderr, "invalid max depth: must be 0 or more")
+		os.Exit(exitBadArgs)
+	}
+
+	if *flagWorkers < 1 {
+		fmt.Fprintln(os.Stderr, "invalid workers: must be at least 1")
+		os.Exit(exitBadArgs)
 	}
 
-	// path should be the last argument
-	scanPath := os.Args[len(os.Args)-1]
+	if *flagSample < 0 {
+		fmt.Fprintln(os.Stderr, "invalid sample size: must not be negative")
+		os.Exit(exitBadArgs)
+	}
+	if *flagSample > 0 {
+		if flagsErr := checkSampleFlags(); flagsErr != nil {
+			fmt.Fprintln(os.Stderr, flagsErr)
+			os.Exit(exitBadArgs)
+		}
+	}
 
 	scanResults := make(chan scanResult)
 
-	// try and use beets
-	if len(*FlagBeetsDBPath) > 0 {
-		if !*flagJsonOutput {
+	// the number of folders found when sampling, set before scanResults is closed
+	var candidateCnt int64
+
+	// the progress bar replaces the dots on a terminal, the folders of a filesystem scan are counted first for the ETA
+	var progress *progressBar
+	if logOutput && *flagProgress && isTerminal(os.Stdout) {
+		var total int64
+		if len(listedFolders) > 0 {
+			total = int64(len(listedFolders))
+		} else if len(*flagFilesFrom) == 0 && len(*FlagBeetsDBPath) == 0 && *flagSample == 0 {
+			for _, root := range scanPaths {
+				fsys := remoteFS
+				if fsys == nil {
+					fsys = os.DirFS(root)
+				}
+
+				rootCnt, countErr := countFolders(throttleScan(fsys))
+				if countErr != nil {
+					fmt.Fprintln(os.Stderr, countErr)
+					os.Exit(exitScanError)
+				}
+				total = total + rootCnt
+			}
+		}
+		progress = newProgressBar(os.Stdout, total)
+	}
+
+	// the files found by this scan, for -max-files
+	scannedFiles := &fileCounter{}
+
+	// use exactly the listed files
+	if len(*flagFilesFrom) > 0 {
+		if logOutput {
+			fmt.Println("Using files listed in", *flagFilesFrom)
+		}
+
+		go func() {
+			crawlErr := crawlFileList(listedFiles, scanResults)
+			if crawlErr != nil {
+				fmt.Fprintln(os.Stderr, crawlErr)
+				os.Exit(exitScanError)
+			}
+			close(scanResults)
+		}()
+
+		// crawl exactly the listed folders
+	} else if len(listedFolders) > 0 {
+		if logOutput {
+			fmt.Println("Using folders listed in", *flagPathsFrom)
+		}
+
+		go func() {
+			crawlPathList(scanPath, listedFolders, *flagWorkers, scannedFiles, scanResults)
+			close(scanResults)
+		}()
+
+		// try and use beets
+	} else if len(*FlagBeetsDBPath) > 0 {
+		if logOutput {
 			fmt.Println("Using Beets database file", *FlagBeetsDBPath)
 		}
 
 		// crawl the beets database
 		go func() {
-			crawlErr := crawlBeetsDB(*FlagBeetsDBPath, scanResults)
+			crawlErr := crawlBeetsDB(*FlagBeetsDBPath, scanPath, beetsAttrKey, beetsAttrValue, *flagWorkers, scannedFiles, scanResults)
 			if crawlErr != nil {
-				fmt.Println(crawlErr)
-				os.Exit(1)
+				fmt.Fprintln(os.Stderr, crawlErr)
+				os.Exit(exitScanError)
 			}
 			close(scanResults)
 		}()
 
 		// otherwise scan the filesystem
 	} else {
-		if !*flagJsonOutput {
-			fmt.Println("Beets database not specified, scanning", scanPath)
+		if logOutput {
+			fmt.Println("Beets database not specified, scanning", scanTarget)
+		}
+
+		// folders scanned by an interrupted run are restored from the scan checkpoint
+		if *flagResume {
+			var checkpointErr error
+			scanCheckpoint, checkpointErr = newScanCheckpointer(*flagTorrentName+".scan.checkpoint", scanPaths)
+			if checkpointErr != nil {
+				fmt.Fprintln(os.Stderr, checkpointErr)
+				os.Exit(exitScanError)
+			}
+			if logOutput && scanCheckpoint.resumed() > 0 {
+				fmt.Println("Resuming scan,", scanCheckpoint.resumed(), "folders already scanned")
+			}
 		}
 
 		go func() {
-			walkErr := crawlFs(scanPath, scanResults)
-			if walkErr != nil {
-				fmt.Println(walkErr)
-				os.Exit(1)
+			for _, root := range scanPaths {
+				fsys := remoteFS
+				if fsys == nil {
+					fsys = os.DirFS(root)
+				} else {
+					root = scanPath
+				}
+
+				rootCandidateCnt, walkErr := crawlFs(throttleScan(fsys), root, *flagWorkers, *flagSample, scannedFiles, scanResults)
+				if walkErr != nil {
+					if scanCheckpoint != nil {
+						if flushErr := scanCheckpoint.flush(); flushErr != nil {
+							fmt.Fprintln(os.Stderr, flushErr)
+						}
+					}
+					fmt.Fprintln(os.Stderr, walkErr)
+					os.Exit(exitScanError)
+				}
+				candidateCnt = candidateCnt + rootCandidateCnt
 			}
 			close(scanResults)
 		}()
@@ -128,139 +738,657 @@ func main() {
 
 	// stats stores the results of the scan
 	stats := Stats{
-		Path:             scanPath,
-		TotalFileSize:    "0 MB",
-		AverageAlbumSize: "0 MB",
-		MagnetURL:        "",
-		TorrentFileName:  "",
+		Path:            scanTarget,
+		Paths:           multiplePaths(scanPaths),
+		BytesByType:     map[FileType]int64{},
+		MagnetURL:       "",
+		TorrentFileName: "",
 	}
 
 	albums := []MusicFolder{}
 	skippedFolders := []string{}
+	depthTruncated := []string{}
 	errors := []error{}
 	fd := []fileData{}
+	firstAlbum := "" // path of the first included album, the album of a single album torrent
+	flacFiles := map[string]int64{}
+	albumSizes := []int64{}
+
+	// the artist and genre of the albums are only known in beets mode
+	var byArtist, byGenre map[string]*GroupStats
+	if len(*FlagBeetsDBPath) > 0 {
+		byArtist = map[string]*GroupStats{}
+		byGenre = map[string]*GroupStats{}
+	}
+
+	var ctdbClient ctdb.CTDB
+	if *flagCTDBVerify {
+		cacheDir, cacheErr := os.UserCacheDir()
+		if cacheErr != nil {
+			fmt.Fprintln(os.Stderr, cacheErr)
+			os.Exit(exitScanError)
+		}
+
+		var ctdbErr error
+		ctdbClient, ctdbErr = ctdb.New(filepath.Join(cacheDir, "milkdud", "ctdb"))
+		if ctdbErr != nil {
+			fmt.Fprintln(os.Stderr, ctdbErr)
+			os.Exit(exitScanError)
+		}
+	}
 
 	// loop through the music folders discovered
 	for result := range scanResults {
+		if isTooManyFiles(result.err) {
+			fmt.Fprintln(os.Stderr, "scan aborted:", result.err)
+			os.Exit(exitScanError)
+		}
+
+		if isJunkDirSkipped(result.err) {
+			stats.JunkDirCnt = stats.JunkDirCnt + 1
+			continue
+		}
+
+		// ignored folders are left out like the walk of a filesystem scan leaves them out
+		if isIgnoredDir(result.err) {
+			continue
+		}
+
+		// truncated directories aren't errors but are reported on their own
+		if p, exceeded := isDepthExceeded(result.err); exceeded {
+			stats.DepthTruncatedCnt = stats.DepthTruncatedCnt + 1
+			depthTruncated = append(depthTruncated, p)
+			continue
+		}
+
 		if result.err != nil {
 			stats.Errors = stats.Errors + 1
 			errors = append(errors, result.err)
-			if !*flagJsonOutput {
+			if progress != nil {
+				progress.add(0, true)
+			} else if logOutput {
 				fmt.Printf("x")
 			}
+
+			// the scan checkpoint is kept so the scan can be resumed once the folder is fixed
+			if *flagOnError == onErrorAbort {
+				if logOutput {
+					fmt.Printf("\n")
+				}
+				if scanCheckpoint != nil {
+					if flushErr := scanCheckpoint.flush(); flushErr != nil {
+						fmt.Fprintln(os.Stderr, flushErr)
+					}
+				}
+				fmt.Fprintln(os.Stderr, "scan aborted:", result.err)
+				os.Exit(exitScanError)
+			}
 			continue
 		} else {
-			if !*flagJsonOutput {
+			if progress != nil {
+				progress.add(result.folder.TotalBytes, false)
+			} else if logOutput {
 				fmt.Printf(".")
 			}
 		}
 
 		folder := result.folder
-		stats.FoldersScanned = stats.FoldersScanned + 1
 
-		// we ignore any folders that don't have an accurip log
-		if folder.HasAccurip || *flagIgnoreRipLogs {
-			if folder.HasAccurip {
-				stats.AccuripFolderCnt = stats.AccuripFolderCnt + 1
+		// albums -match doesn't match or outside -min-album-size and -max-album-size are left out of the stats
+		// and the torrent, the walk already reported the junk, too deep and excluded folders
+		verdict := folderFilters.accept(folder, "")
+		switch verdict {
+		case folderUnmatched:
+			stats.UnmatchedCnt = stats.UnmatchedCnt + 1
+			continue
+		case folderSizeFiltered:
+			stats.SizeFiltered = append(stats.SizeFiltered, folder.Path)
+			continue
+		}
+
+		if ctdbClient != nil && folder.HasAccurip {
+			if ctdbErr := verifyCTDB(ctdbClient, folder); ctdbErr != nil {
+				stats.Errors = stats.Errors + 1
+				errors = append(errors, ctdbErr)
+			} else if folder.CTDBChecked && !folder.CTDBFound {
+				stats.CTDBNotFound = append(stats.CTDBNotFound, folder.Path)
 			}
-			stats.FolderCnt = stats.FolderCnt + 1
-			stats.TotalFileSizeBytes = stats.TotalFileSizeBytes + folder.TotalBytes
-			stats.TotalFileSize = byteCountSI(stats.TotalFileSizeBytes)
-			stats.TotalFiles = stats.TotalFiles + folder.FileCnt
-			stats.AverageAlbumSizeBytes = stats.TotalFileSizeBytes / stats.FolderCnt
-			stats.AverageAlbumSize = byteCountSI(stats.AverageAlbumSizeBytes)
-			albums = append(albums, *folder)
+		}
+
+		folder.assess()
+		stats.FoldersScanned = stats.FoldersScanned + 1
+
+		if byArtist != nil {
+			addToGroup(byArtist, folder.Artist, folder)
+			addToGroup(byGenre, folder.Genre, folder)
+		}
+
+		// the expected flac count is only known in beets mode
+		if folder.ExpectedTrackCnt > 0 && folder.trackCnt() != folder.ExpectedTrackCnt {
+			stats.IncompleteAlbums = append(stats.IncompleteAlbums, folder.Path)
+		}
+
+		stats.LintWarningCnt = stats.LintWarningCnt + int64(len(folder.LintWarnings))
 
+		if folder.LogSigned && !folder.LogChecksumValid {
+			stats.LogChecksumMismatches = append(stats.LogChecksumMismatches, folder.Path)
+		}
+
+		if *flagFindDupes && !folder.Archive {
 			for _, file := range folder.Files {
 				if file.FileType == FileTypeFlac {
-					stats.TotalFlacFiles = stats.TotalFlacFiles + 1
+					flacFiles[file.Path] = file.Size
+				}
+			}
+		}
+
+		// we ignore any folders that don't have an accurip log, unless only the torrent is limited to them
+		if verdict == folderIncluded {
+			if *flagM3u && !folder.Archive {
+				playlist, playlistErr := writePlaylist(folder)
+				if playlistErr != nil {
+					stats.Errors = stats.Errors + 1
+					errors = append(errors, playlistErr)
+				} else if len(playlist) > 0 && *flagM3uInclude {
+					info, statErr := os.Stat(playlist)
+					if statErr == nil {
+						folder.TotalBytes = folder.TotalBytes + info.Size()
+						folder.FileCnt = folder.FileCnt + 1
+						folder.Files = append(folder.Files, MusicFile{
+							Path:     playlist,
+							Name:     info.Name(),
+							Size:     info.Size(),
+							FileType: FileTypeM3u,
+						})
+					}
+				}
+			}
+
+			stats.addFolder(folder)
+			if stats.FolderCnt == 1 {
+				firstAlbum = folder.Path
+			}
+			albumSizes = append(albumSizes, folder.TotalBytes)
+
+			// stream the album instead of holding it in memory
+			if *flagNDJSONOutput {
+				b, _ := json.Marshal(newEnvelope(kindAlbum, folder))
+				fmt.Println(string(b))
+			}
+			if !*flagNDJSONOutput || *flagWatch || len(*flagSince) > 0 || *flagTocReport || len(*flagServe) > 0 || *flagTop > 0 || nameTemplate != nil {
+				albums = append(albums, *folder)
+			}
+
+			// the files inside an archive can't be added to a torrent without extracting them
+			if !folder.Archive {
+				for _, file := range folder.Files {
+					fd = append(fd, fileData{filepath.Dir(file.Path), file.Name, file.Size, file.FileType, folder.HasAccurip})
 				}
-				fd = append(fd, fileData{folder.Path, file.Name, file.Size})
 			}
 
 		} else {
-			if folder.Path != scanPath {
+			if folder.Path != scanPath && !isScanRoot(folder.Path, scanPaths) {
 				skippedFolders = append(skippedFolders, folder.Path)
 			}
 		}
 	}
 
-	if !*flagJsonOutput {
+	if progress != nil {
+		progress.finish()
+	}
+
+	// the checkpoint is kept after folder errors so running again only scans the failed folders
+	if scanCheckpoint != nil {
+		var checkpointErr error
+		if stats.Errors > 0 {
+			checkpointErr = scanCheckpoint.flush()
+		} else {
+			checkpointErr = scanCheckpoint.remove()
+		}
+		if checkpointErr != nil {
+			fmt.Fprintln(os.Stderr, checkpointErr)
+		}
+	}
+	if logOutput {
 		fmt.Printf("\n")
 	}
 
+	stats.summarize()
+	if *flagSample > 0 {
+		stats.estimate(candidateCnt)
+	}
+
+	if *flagFindDupes {
+		dupes, dupeErrs := findDuplicates(flacFiles)
+		stats.Duplicates = dupes
+		for _, dupe := range dupes {
+			stats.ReclaimableBytes = stats.ReclaimableBytes + dupe.ReclaimableBytes
+		}
+		stats.Errors = stats.Errors + len(dupeErrs)
+		errors = append(errors, dupeErrs...)
+	}
+
+	// folders arrive in the order they finish, they're sorted so the output of consecutive runs can be diffed
+	sortScanResults(albums, skippedFolders, depthTruncated, errors, &stats)
+
+	if len(*flagSince) > 0 {
+		stats.Diff = diffAlbums(*flagSince, previousAlbums, albums)
+	}
+
+	metrics.update(stats, albumSizes)
+
+	// albums needing attention are listed first
 	detailedStats := DetailedStats{
 		stats,
-		albums,
+		sortedByStatus(albums),
 		skippedFolders,
 		errors,
+		byArtist,
+		byGenre,
+		nil,
+		nil,
+		depthTruncated,
+	}
+
+	if *flagTop > 0 {
+		detailedStats.LargestAlbums, detailedStats.SmallestAlbums = albumExtremes(albums, *flagTop)
+	}
+
+	// quiet mode still reports errors
+	if *flagQuiet {
+		for _, err := range detailedStats.Errors {
+			fmt.Fprintln(os.Stderr, err)
+		}
 	}
 
 	// summarize the album size results
-	if !*flagJsonOutput {
+	if logOutput {
 		fmt.Println("Completed successfully")
+		if stats.Sample != nil {
+			fmt.Println("Sampled", stats.Sample.SampledFolders, "of", stats.Sample.CandidateFolders, "folders, the counts below are for the sample only")
+		}
 		fmt.Println("Folders:", stats.FoldersScanned)
-		fmt.Println("Folders with Accurip logs:", stats.AccuripFolderCnt)
+		fmt.Println("Folders with Accurip logs:", stats.AccuripFolderCnt, fmt.Sprintf("(%.1f%%)", stats.AccuripCoveragePercent))
+		fmt.Println("Folders with ReplayGain:", stats.ReplayGainFolderCnt, fmt.Sprintf("(%.1f%%)", stats.ReplayGainCoveragePercent))
 		fmt.Println("Files:", stats.TotalFiles)
 		fmt.Println("Flac files:", stats.TotalFlacFiles)
+		if audioFormats[FileTypeM4a] {
+			fmt.Println("Alac files:", stats.TotalAlacFiles)
+		}
+		if audioFormats[FileTypeWav] {
+			fmt.Println("Wav files:", stats.TotalWavFiles)
+		}
+		if stats.TotalImageFiles > 0 {
+			fmt.Println("Disc images:", stats.TotalImageFiles)
+		}
 		fmt.Println("Total file size:", stats.TotalFileSize, fmt.Sprintf("(%d bytes)", stats.TotalFileSizeBytes))
 		fmt.Println("Average album size:", stats.AverageAlbumSize, fmt.Sprintf("(%d bytes)", stats.AverageAlbumSizeBytes))
-		if len(detailedStats.Errors) > 0 {
-			fmt.Println("Errors:")
-			for _, err := range detailedStats.Errors {
-				fmt.Println(" ", err)
+		fmt.Println("Total duration:", stats.TotalDuration)
+		if len(stats.BytesByType) > 0 {
+			fmt.Println("Bytes by type:")
+			fileTypes := []string{}
+			for fileType := range stats.BytesByType {
+				fileTypes = append(fileTypes, string(fileType))
+			}
+			sort.Strings(fileTypes)
+			for _, fileType := range fileTypes {
+				b := stats.BytesByType[FileType(fileType)]
+				fmt.Println(" ", fileType, byteCountSI(b), fmt.Sprintf("(%d bytes)", b))
 			}
 		}
-		if *FlagDetailedStats {
+		if *flagTop > 0 {
+			fmt.Println("Largest albums:")
+			for _, album := range detailedStats.LargestAlbums {
+				fmt.Println(" ", album.Path, byteCountSI(album.TotalBytes))
+			}
+			fmt.Println("Smallest albums:")
+			for _, album := range detailedStats.SmallestAlbums {
+				fmt.Println(" ", album.Path, byteCountSI(album.TotalBytes))
+			}
+		}
+		fmt.Println("Albums with warnings:", stats.WarningAlbumCnt)
+		fmt.Println("Albums with problems:", stats.ProblemAlbumCnt)
+		if stats.Sample != nil {
+			fmt.Println("Estimated albums:", stats.Sample.EstimatedAlbumCnt)
+			fmt.Println("Estimated folders with Accurip logs:", stats.Sample.EstimatedAccuripFolderCnt)
+			fmt.Println("Estimated files:", stats.Sample.EstimatedTotalFiles)
+			fmt.Println("Estimated total file size:", stats.Sample.EstimatedTotalFileSize, fmt.Sprintf("(%d bytes)", stats.Sample.EstimatedTotalBytes))
+			fmt.Println("Estimated total duration:", formatDuration(stats.Sample.EstimatedDurationSeconds))
+		}
+		if len(stats.IncompleteAlbums) > 0 {
+			fmt.Println("Incomplete albums:")
+			for _, p := range stats.IncompleteAlbums {
+				fmt.Println(" ", p)
+			}
+		}
+		if *flagLint {
+			fmt.Println("Lint warnings:", stats.LintWarningCnt)
+		}
+		if stats.JunkDirCnt > 0 {
+			fmt.Println("Junk folders skipped:", stats.JunkDirCnt)
+		}
+		if stats.DepthTruncatedCnt > 0 {
+			fmt.Println("Exceeded max depth of", *flagMaxDepth, "directories:", stats.DepthTruncatedCnt)
+			for _, p := range depthTruncated {
+				fmt.Println(" ", p)
+			}
+		}
+		if stats.UnmatchedCnt > 0 {
+			fmt.Println("Folders not matching", *flagMatch+":", stats.UnmatchedCnt)
+		}
+		if len(stats.SizeFiltered) > 0 {
+			fmt.Println("Albums outside the size limits:", len(stats.SizeFiltered))
+			for _, p := range stats.SizeFiltered {
+				fmt.Println(" ", p)
+			}
+		}
+		if len(stats.CTDBNotFound) > 0 {
+			fmt.Println("Not in the CueTools database:")
+			for _, p := range stats.CTDBNotFound {
+				fmt.Println(" ", p)
+			}
+		}
+		if len(stats.LogChecksumMismatches) > 0 {
+			fmt.Println("Log checksum mismatches:")
+			for _, p := range stats.LogChecksumMismatches {
+				fmt.Println(" ", p)
+			}
+		}
+		if *flagFindDupes {
+			fmt.Println("Duplicate files:", len(stats.Duplicates))
+			for _, dupe := range stats.Duplicates {
+				fmt.Println(" ", dupe.Hash, byteCountSI(dupe.Size))
+				for _, p := range dupe.Paths {
+					fmt.Println("  ", p)
+				}
+			}
+			fmt.Println("Reclaimable:", byteCountSI(stats.ReclaimableBytes), fmt.Sprintf("(%d bytes)", stats.ReclaimableBytes))
+		}
+		if stats.Diff != nil {
+			fmt.Println("Changes since", stats.Diff.Since)
+			for _, p := range stats.Diff.Added {
+				fmt.Println("  added:", p)
+			}
+			for _, p := range stats.Diff.Removed {
+				fmt.Println("  removed:", p)
+			}
+			for _, change := range stats.Diff.Changed {
+				fmt.Println("  changed:", change.Path, fmt.Sprintf("files %d -> %d, size %s -> %s", change.PreviousFileCnt, change.FileCnt, byteCountSI(change.PreviousTotalBytes), byteCountSI(change.TotalBytes)))
+				if len(change.PreviousPath) > 0 {
+					fmt.Println("    moved from:", change.PreviousPath)
+				}
+			}
+		}
+		if len(detailedStats.Errors) > 0 {
+			fmt.Println("Errors:")
+			for _, err := range detailedStats.Errors {
+				fmt.Println(" ", err)
+			}
+		}
+		if *FlagDetailedStats && byArtist != nil {
+			fmt.Println("By artist:")
+			for _, artist := range sortedGroupKeys(byArtist) {
+				group := byArtist[artist]
+				fmt.Println(" ", artist, group.AlbumCnt, fmt.Sprintf("%.1f%%", group.AccuripCoveragePercent), byteCountSI(group.TotalBytes))
+			}
+			fmt.Println("By genre:")
+			for _, genre := range sortedGroupKeys(byGenre) {
+				group := byGenre[genre]
+				fmt.Println(" ", genre, group.AlbumCnt, fmt.Sprintf("%.1f%%", group.AccuripCoveragePercent), byteCountSI(group.TotalBytes))
+			}
+		}
+		if *FlagDetailedStats {
 			fmt.Println("Scanned albums:")
 			for _, mf := range detailedStats.Albums {
-				fmt.Println(" ", mf.Path, mf.HasAccurip, mf.FlacCnt, mf.FileCnt, byteCountSI(mf.TotalBytes))
+				fmt.Println(" ", mf.Path, mf.Status, mf.HasAccurip, mf.FlacCnt, mf.FileCnt, byteCountSI(mf.TotalBytes), formatDuration(mf.TotalDurationSeconds))
+				for _, issue := range mf.Issues {
+					fmt.Println("   issue:", issue)
+				}
 				for _, file := range mf.Files {
 					fmt.Println("  ", file.Name)
 				}
+				if mf.Release != nil {
+					fmt.Println("   musicbrainz:", mf.Release.Date, mf.Release.Country, mf.Release.Label, mf.Release.CatalogNumber)
+				}
+				if mf.CueTrackCnt > 0 {
+					layout := "per track"
+					if mf.CueImage {
+						layout = "disc image"
+					}
+					fmt.Println("   cue tracks:", mf.CueTrackCnt, layout)
+					if !mf.CueImage && mf.CueTrackCnt != mf.audioFileCnt() {
+						fmt.Println("   cue track count doesn't match audio file count:", mf.audioFileCnt())
+					}
+				}
+				for _, name := range mf.MissingCueFiles {
+					fmt.Println("   missing cue file:", name)
+				}
+				if mf.AccuripConfidence > 0 {
+					fmt.Println("   accurip confidence:", mf.AccuripConfidence)
+				}
+				if mf.CTDBChecked && mf.CTDBFound {
+					fmt.Println("   ctdb confidence:", mf.CTDBConfidence)
+				} else if mf.CTDBChecked {
+					fmt.Println("   not in the CueTools database")
+				}
+				if mf.FlacCnt > 0 && !mf.HasReplayGain {
+					fmt.Println("   missing replaygain")
+				}
+				for _, track := range mf.MissingTracks {
+					fmt.Println("   missing from disk:", track)
+				}
+				for _, warning := range mf.LintWarnings {
+					fmt.Println("   lint:", warning)
+				}
+				for _, file := range mf.UntrackedFiles {
+					fmt.Println("   missing from beets:", file)
+				}
 			}
 		}
 	}
 
+	// the report is printed in quiet mode so it can be piped on its own
+	if *flagTocReport && !*flagJsonOutput && !*flagNDJSONOutput {
+		printTocReport(albums)
+	}
+
+	// keep watching for changes instead of creating a torrent
+	if *flagWatch {
+		watchErr := watch(scanPath, stats, albums, metrics, folderFilters, logOutput)
+		if watchErr != nil {
+			fmt.Fprintln(os.Stderr, watchErr)
+			os.Exit(exitScanError)
+		}
+		os.Exit(exitOK)
+	}
+
+	// catch scans of the wrong path before doing anything with the results
+	if stats.AccuripFolderCnt < *flagRequireAccurip {
+		fmt.Fprintf(os.Stderr, "found %d accurip albums, at least %d required\n", stats.AccuripFolderCnt, *flagRequireAccurip)
+		os.Exit(exitScanError)
+	}
+
+	// preview the torrent contents without hashing anything
+	if *flagListFiles {
+		root, rootName := torrentRoot(scanPath, firstAlbum, stats.FolderCnt)
+		printFileList(torrentFiles(fd, torrentExclude), root, rootName)
+		os.Exit(exitOK)
+	}
+
 	// create torrent file for all album files
 	if *flagCreateTorrent {
 		if stats.TotalFileSizeBytes == 0 {
-			if !*flagJsonOutput {
+			if logOutput {
 				fmt.Println("No files, skipping torrent creation")
 			}
 		} else {
 
-			stats.TorrentFileName = fmt.Sprintf("%s.torrent", *flagTorrentName)
+			// report every file that changed since the scan before spending time hashing
+			if *flagVerifyPaths {
+				stats.PathProblems = verifyPaths(torrentFiles(fd, torrentExclude), remoteFS, scanPath)
+				if len(stats.PathProblems) > 0 {
+					if *flagJsonOutput || *flagNDJSONOutput {
+						b, _ := json.MarshalIndent(newEnvelope(kindStats, stats), "", "  ")
+						fmt.Println(string(b))
+					}
+					fmt.Fprintln(os.Stderr, "files changed since the scan:", len(stats.PathProblems))
+					for _, problem := range stats.PathProblems {
+						switch problem.Problem {
+						case "size_changed":
+							fmt.Fprintln(os.Stderr, " ", problem.Problem, problem.Path, problem.ExpectedSize, "->", problem.Size)
+						case "unreadable":
+							fmt.Fprintln(os.Stderr, " ", problem.Problem, problem.Path, problem.Error)
+						default:
+							fmt.Fprintln(os.Stderr, " ", problem.Problem, problem.Path)
+						}
+					}
+					os.Exit(exitTorrentError)
+				}
+			}
 
-			comment := fmt.Sprintf("%d accurip albums", stats.AccuripFolderCnt)
-			if len(*FlagTorrentTag) > 0 {
-				comment = fmt.Sprintf("%s (%s)", comment, *FlagTorrentTag)
+			stats.TorrentFileName = fmt.Sprintf("%s.torrent", sanitizeFileName(*flagTorrentName))
+			if nameTemplate != nil {
+				name, nameErr := torrentName(nameTemplate, stats, albums, *FlagTorrentTag)
+				if nameErr != nil {
+					fmt.Fprintln(os.Stderr, nameErr)
+					os.Exit(exitTorrentError)
+				}
+				stats.TorrentFileName = fmt.Sprintf("%s.torrent", name)
+			}
+			if len(*flagAppendTo) > 0 {
+				stats.TorrentFileName = *flagAppendTo
 			}
 
-			announce := []string{}
-			if len(*flagAnnounce) > 0 {
-				announce = strings.Split(*flagAnnounce, ",")
+			comment, commentErr := torrentComment(commentTemplate, stats, *FlagTorrentTag)
+			if commentErr != nil {
+				fmt.Fprintln(os.Stderr, commentErr)
+				os.Exit(exitTorrentError)
 			}
 
-			tf, tfErr := torrent.New(scanPath, comment, announce, !*flagJsonOutput)
+			torrentRootPath, torrentRootName := torrentRoot(scanPath, firstAlbum, stats.FolderCnt)
+
+			var tf torrent.TorrentFile
+			var tfErr error
+			if len(*flagAppendTo) > 0 {
+				// the settings of the existing torrent are kept
+				tf, tfErr = torrent.Open(*flagAppendTo, scanPath, logOutput)
+			} else {
+				tf, tfErr = torrent.New(torrentRootPath, torrentRootName, comment, announce, logOutput)
+			}
 			if tfErr != nil {
-				fmt.Println(tfErr)
-				os.Exit(1)
+				fmt.Fprintln(os.Stderr, tfErr)
+				os.Exit(exitTorrentError)
+			}
+
+			tf.SetReadRate(readRate)
+			tf.SetHashWorkers(*flagHashWorkers)
+			// the files of a remote library are hashed where they are, each file is read over the network once
+			if remoteFS != nil {
+				tfFS, subErr := remoteSub(remoteFS, scanPath, torrentRootPath)
+				if subErr != nil {
+					fmt.Fprintln(os.Stderr, subErr)
+					os.Exit(exitTorrentError)
+				}
+				tf.SetFS(tfFS)
+				if logOutput {
+					fmt.Println("Hashing the torrent files over the network from", scanTarget)
+				}
+			}
+			if len(webSeeds) > 0 {
+				tf.SetWebSeeds(webSeeds)
+			}
+			tf.SetManifest(*flagManifestSha256)
+			tf.SetManifestHash(manifestHash)
+			if *flagResume {
+				tf.SetCheckpoint(stats.TorrentFileName + ".checkpoint")
+			}
+			if len(*flagAppendTo) == 0 {
+				tf.SetPrivate(!*flagPublic)
+				tf.SetCreatedBy(*flagCreatedBy)
+				tf.SetRootFromPath(*flagRootFromPath)
 			}
 
-			for _, file := range fd {
-				tf.AddFile(filepath.Join(file.path, file.name), file.size)
+			// without a creation date the same files always produce the same torrent file
+			if *flagNoDate {
+				tf.SetCreationDate(0)
+			}
+
+			// scaled album art is written to a temp dir that only lives until the torrent is created
+			artDir, artDirErr := os.MkdirTemp("", "milkdud-art")
+			if artDirErr != nil {
+				fmt.Fprintln(os.Stderr, artDirErr)
+				os.Exit(exitTorrentError)
+			}
+			defer os.RemoveAll(artDir)
+
+			for _, file := range torrentFiles(fd, torrentExclude) {
+				p := filepath.Join(file.path, file.name)
+
+				if *flagArtMaxDim > 0 && (file.fileType == FileTypeJpeg || file.fileType == FileTypePng) {
+					scaled, scaleErr := scaleArt(p, *flagArtMaxDim, artDir)
+					if scaleErr != nil {
+						fmt.Fprintln(os.Stderr, scaleErr)
+						os.Exit(exitTorrentError)
+					}
+
+					if len(scaled) > 0 {
+						info, statErr := os.Stat(scaled)
+						if statErr != nil {
+							fmt.Fprintln(os.Stderr, statErr)
+							os.Exit(exitTorrentError)
+						}
+						if addErr := tf.AddFileFrom(p, scaled, info.Size()); addErr != nil {
+							fmt.Fprintln(os.Stderr, addErr)
+							os.Exit(exitTorrentError)
+						}
+						continue
+					}
+				}
+
+				if addErr := tf.AddFile(p, file.size); addErr != nil {
+					fmt.Fprintln(os.Stderr, addErr)
+					os.Exit(exitTorrentError)
+				}
 			}
 
 			createErr := tf.Create(stats.TorrentFileName)
 			if createErr != nil {
-				fmt.Println(createErr)
-				os.Exit(1)
+				fmt.Fprintln(os.Stderr, createErr)
+				os.Exit(exitTorrentError)
 			}
 
 			stats.MagnetURL = tf.MagnetURL()
 
-			if !*flagJsonOutput {
+			// check the web seeds before the torrent is published
+			if len(webSeeds) > 0 {
+				webSeedErrs, verifyErr := verifyWebSeeds(stats.TorrentFileName, webSeeds)
+				if verifyErr != nil {
+					fmt.Fprintln(os.Stderr, verifyErr)
+					os.Exit(exitTorrentError)
+				}
+				for _, webSeedErr := range webSeedErrs {
+					fmt.Fprintln(os.Stderr, webSeedErr)
+				}
+				stats.Errors = stats.Errors + len(webSeedErrs)
+			}
+
+			if len(*flagDumpTorrent) > 0 {
+				dumpErr := dumpTorrent(stats.TorrentFileName)
+				if dumpErr != nil {
+					fmt.Fprintln(os.Stderr, dumpErr)
+					os.Exit(exitTorrentError)
+				}
+			}
+
+			if len(*flagMagnetOut) > 0 {
+				magnetErr := appendLine(*flagMagnetOut, stats.MagnetURL)
+				if magnetErr != nil {
+					fmt.Fprintln(os.Stderr, magnetErr)
+					os.Exit(exitTorrentError)
+				}
+			}
+
+			if logOutput {
 				fmt.Println("Magnet URL:", stats.MagnetURL)
 				fmt.Println("Torrent created:", stats.TorrentFileName)
 			}
@@ -268,18 +1396,64 @@ func main() {
 
 	}
 
-	if *flagJsonOutput {
+	// keep serving the results, including the created torrent
+	if len(*flagServe) > 0 {
+		serveErr := serve(*flagServe, stats, albums)
+		if serveErr != nil {
+			fmt.Fprintln(os.Stderr, serveErr)
+			os.Exit(exitScanError)
+		}
+		os.Exit(exitOK)
+	}
+
+	if *flagNDJSONOutput {
+		b, _ := json.Marshal(newEnvelope(kindStats, stats))
+		fmt.Println(string(b))
+	} else if *flagJsonOutput {
 		var b []byte
 
 		if *FlagDetailedStats {
-			b, _ = json.MarshalIndent(detailedStats, "", "  ")
+			b, _ = json.MarshalIndent(newEnvelope(kindDetailedStats, detailedStats), "", "  ")
 		} else {
-			b, _ = json.MarshalIndent(stats, "", "  ")
+			b, _ = json.MarshalIndent(newEnvelope(kindStats, stats), "", "  ")
 		}
 
 		fmt.Println(string(b))
-		os.Exit(0)
 	}
+
+	// keep the metrics of the scan available until interrupted
+	if metrics != nil {
+		metrics.wait(*flagMetrics)
+	}
+
+	if *flagFailOnError && stats.Errors > 0 {
+		os.Exit(exitScanError)
+	}
+
+	os.Exit(exitOK)
+}
+
+// sortScanResults sorts the albums, their files, the folder lists and the errors of a scan by path
+func sortScanResults(albums []MusicFolder, skippedFolders, depthTruncated []string, errs []error, s *Stats) {
+	sort.SliceStable(albums, func(i, j int) bool {
+		return natsort.Less(albums[i].Path, albums[j].Path)
+	})
+
+	for _, album := range albums {
+		sort.SliceStable(album.Files, func(i, j int) bool {
+			return natsort.Less(album.Files[i].Path, album.Files[j].Path)
+		})
+	}
+
+	for _, paths := range [][]string{skippedFolders, depthTruncated, s.IncompleteAlbums, s.LogChecksumMismatches, s.CTDBNotFound, s.SizeFiltered} {
+		sort.SliceStable(paths, func(i, j int) bool {
+			return natsort.Less(paths[i], paths[j])
+		})
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
 }
 
 // byteCountSI returns a human readable byte count
@@ -294,160 +1468,681 @@ func byteCountSI(b int64) string {
 		div *= unit
 		exp++
 	}
-	return fmt.Sprintf("%.1f %cB",
-		float64(b)/float64(div), "kMGTPE"[exp])
+	return fmt.Sprintf("%.1f %cB",
+		float64(b)/float64(div), "kMGTPE"[exp])
+}
+
+// dumpTorrent prints the decoded metainfo of a torrent file as text or json
+func dumpTorrent(fileName string) error {
+	d, loadErr := torrent.Load(fileName)
+	if loadErr != nil {
+		return loadErr
+	}
+
+	if *flagJsonOutput || *flagNDJSONOutput {
+		b, _ := json.MarshalIndent(newEnvelope(kindTorrent, d), "", "  ")
+		fmt.Println(string(b))
+		return nil
+	}
+
+	fmt.Println("Torrent:", fileName)
+	fmt.Println("Info hash:", d.InfoHash)
+	fmt.Println("Name:", d.Name)
+	if len(d.Announce) > 0 {
+		fmt.Println("Announce:", d.Announce)
+	}
+	fmt.Println("Announce list:")
+	for _, tier := range d.AnnounceList {
+		fmt.Println(" ", strings.Join(tier, ", "))
+	}
+	if len(d.WebSeeds) > 0 {
+		fmt.Println("Web seeds:")
+		for _, ws := range d.WebSeeds {
+			fmt.Println(" ", ws)
+		}
+	}
+	fmt.Println("Comment:", d.Comment)
+	fmt.Println("Created by:", d.CreatedBy)
+	fmt.Println("Creation date:", d.CreationDate)
+	fmt.Println("Piece length:", d.PieceLength, fmt.Sprintf("(%d pieces)", d.PieceCnt))
+	fmt.Println("Private:", d.Private)
+	fmt.Println("Source:", d.Source)
+	fmt.Println("Total length:", byteCountSI(d.TotalLength), fmt.Sprintf("(%d bytes)", d.TotalLength))
+	fmt.Println("Files:")
+	for _, file := range d.Files {
+		fmt.Println(" ", file.Path, file.Length)
+	}
+
+	return nil
+}
+
+// parseFormats sets the audio formats to scan from a comma seperated list
+func parseFormats(s string) error {
+	formats := map[FileType]bool{}
+
+	for _, format := range strings.Split(s, ",") {
+		ft := FileType(strings.ToLower(strings.TrimSpace(format)))
+		if len(ft) == 0 {
+			continue
+		}
+
+		if !ft.IsAudio() {
+			return fmt.Errorf("unsupported audio format %s", ft)
+		}
+
+		formats[ft] = true
+	}
+
+	if len(formats) == 0 {
+		return fmt.Errorf("at least one audio format is required")
+	}
+
+	audioFormats = formats
+
+	return nil
+}
+
+// parseExtensions parses a comma seperated list of file extensions ex: m3u,.nfo
+func parseExtensions(s string) map[string]bool {
+	extensions := map[string]bool{}
+
+	for _, ext := range strings.Split(s, ",") {
+		ext = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(ext)), ".")
+		if len(ext) > 0 {
+			extensions[ext] = true
+		}
+	}
+
+	return extensions
+}
+
+// hasExtension returns true if the file name ends with any of the extensions, multi part extensions ex: log.gz are supported
+func hasExtension(name string, extensions map[string]bool) bool {
+	name = strings.ToLower(name)
+	for ext := range extensions {
+		if strings.HasSuffix(name, "."+ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseByteSize parses a byte count with an optional SI suffix ex: 50M, an empty string is 0
+func parseByteSize(s string) (int64, error) {
+	s = strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	if len(s) == 0 {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	if i := strings.IndexByte("KMGTPE", s[len(s)-1]); i >= 0 {
+		for ; i >= 0; i-- {
+			multiplier *= 1000
+		}
+		s = s[:len(s)-1]
+	}
+
+	n, parseErr := strconv.ParseInt(s, 10, 64)
+	if parseErr != nil {
+		return 0, fmt.Errorf("invalid byte size %s", s)
+	}
+
+	if n < 0 {
+		return 0, fmt.Errorf("byte size must not be negative")
+	}
+
+	return n * multiplier, nil
+}
+
+// appendLine appends a line of text to a file, creating it if needed
+func appendLine(fileName, line string) error {
+	f, openErr := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if openErr != nil {
+		return fmt.Errorf("error opening file: %s", openErr)
+	}
+	defer f.Close()
+
+	if _, writeErr := fmt.Fprintln(f, line); writeErr != nil {
+		return fmt.Errorf("error writing file: %s", writeErr)
+	}
+
+	return nil
+}
+
+// parseAnnounce splits a comma seperated list of announce URLs and validates each one
+func parseAnnounce(s string) ([]string, error) {
+	announce := []string{}
+
+	for _, a := range strings.Split(s, ",") {
+		a = strings.TrimSpace(a)
+
+		// ignore empty entries from trailing commas
+		if len(a) == 0 {
+			continue
+		}
+
+		if validateErr := validateAnnounce(a); validateErr != nil {
+			return nil, validateErr
+		}
+
+		announce = append(announce, a)
+	}
+
+	return announce, nil
+}
+
+// validateAnnounce checks an announce URL is a udp, http or https URL with a host
+func validateAnnounce(a string) error {
+	u, parseErr := url.Parse(a)
+	if parseErr != nil {
+		return fmt.Errorf("invalid announce URL %s: %s", a, parseErr)
+	}
+
+	switch u.Scheme {
+	case "udp", "http", "https":
+	default:
+		return fmt.Errorf("invalid announce URL %s: scheme must be udp, http or https", a)
+	}
+
+	if len(u.Host) == 0 {
+		return fmt.Errorf("invalid announce URL %s: missing host", a)
+	}
+
+	return nil
+}
+
+// checkIncompatibleFlags returns an error naming the first of the flags that is set, mode describes what it can't
+// be used with
+func checkIncompatibleFlags(mode string, names ...string) error {
+	for _, name := range names {
+		f := flag.Lookup(name)
+		if f.Value.String() != f.DefValue {
+			return fmt.Errorf("-%s can't be used %s", name, mode)
+		}
+	}
+
+	return nil
+}
+
+// isDepthExceeded returns the directory if err reports it exceeded the max depth
+func isDepthExceeded(err error) (string, bool) {
+	var depthErr depthExceededError
+	if errors.As(err, &depthErr) {
+		return depthErr.path, true
+	}
+	return "", false
+}
+
+// folderDepth returns the number of folders from the scan path to fp, a slash seperated path relative to it
+func folderDepth(fp string) int {
+	if fp == "." {
+		return 0
+	}
+	return strings.Count(fp, "/") + 1
+}
+
+// isJunkDirSkipped returns true if err reports a skipped system or trash folder
+func isJunkDirSkipped(err error) bool {
+	return errors.Is(err, errJunkDir)
+}
+
+// isIgnoredDir returns true if err reports a folder left out by -exclude or a .milkdudignore file
+func isIgnoredDir(err error) bool {
+	return errors.Is(err, errIgnoredDir)
+}
+
+// isTooManyFiles returns true if err aborted a scan for exceeding -max-files
+func isTooManyFiles(err error) bool {
+	return errors.Is(err, errTooManyFiles)
+}
+
+// resolveBeetsLibrary replaces a -b of auto or a beets config directory with the library database from its config.yaml
+func resolveBeetsLibrary() error {
+	configDir := *FlagBeetsDBPath
+	if configDir == "auto" {
+		var dirErr error
+		configDir, dirErr = beets.ConfigDir()
+		if dirErr != nil {
+			return dirErr
+		}
+	} else if info, statErr := os.Stat(configDir); statErr != nil || !info.IsDir() {
+		return nil
+	}
+
+	library, libraryErr := beets.FindLibrary(configDir)
+	if libraryErr != nil {
+		return libraryErr
+	}
+
+	*FlagBeetsDBPath = library
+	return nil
+}
+
+// parseBeetsAttr parses a key=value beets flexible attribute filter, an empty string means no filter
+func parseBeetsAttr(s string) (string, string, error) {
+	if len(s) == 0 {
+		return "", "", nil
+	}
+
+	key, value, found := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !found || len(key) == 0 {
+		return "", "", fmt.Errorf("invalid beets attribute %q, expected key=value", s)
+	}
+
+	return key, strings.TrimSpace(value), nil
+}
+
+// detectAccuripInFile detects the TOCID in an Accurip log file
+func detectAccuripInFile(fsys fs.FS, logFile string) (accuripResult, error) {
+	contents, readErr := fs.ReadFile(fsys, logFile)
+	if readErr != nil {
+		return accuripResult{}, readErr
+	}
+
+	return detectAccurip(contents)
+}
+
+// detectAccurip detects the TOCID in the contents of an Accurip log file
+func detectAccurip(contents []byte) (accuripResult, error) {
+
+	// transparently decompress gzipped logs
+	if bytes.HasPrefix(contents, gzipMagic) {
+		zr, gzipErr := gzip.NewReader(bytes.NewReader(contents))
+		if gzipErr != nil {
+			return accuripResult{}, gzipErr
+		}
+
+		var unzipErr error
+		contents, unzipErr = io.ReadAll(zr)
+		if unzipErr != nil {
+			return accuripResult{}, unzipErr
+		}
+	}
+
+	checksum := verifyLogChecksum(contents)
+	toc := parseLogTOC(strings.Replace(decodeLog(contents), "\x00", "", -1))
+
+	fromEAC, eacErr := detectEACTOCID(string(contents))
+	if eacErr != nil {
+		return accuripResult{}, eacErr
+	}
+	if len(fromEAC.tocID) > 0 {
+		fromEAC.checksum = checksum
+		fromEAC.toc = toc
+		return fromEAC, nil
+	}
+
+	fromCueRipper, cueErr := detectCUERipperTOCID(string(contents))
+	if cueErr != nil {
+		return accuripResult{}, cueErr
+	}
+	if len(fromCueRipper.tocID) > 0 {
+		fromCueRipper.checksum = checksum
+		fromCueRipper.toc = toc
+		return fromCueRipper, nil
+	}
+
+	return accuripResult{checksum: checksum}, nil
+}
+
+// detectEACTOCID detects the TOCID in an Accurip log file generated by EAC
+func detectEACTOCID(str string) (accuripResult, error) {
+
+	// remove \x00 runes (NULL) as EAC tends to put these in the log file
+	str = strings.Replace(str, "\x00", "", -1)
+
+	if strings.Contains(str, "Exact Audio Copy") {
+		matches := tocIDRegexp.FindStringSubmatch(str)
+		if len(matches) > 0 {
+			return accuripResult{
+				tocID:      matches[1],
+				status:     classifyAccurip(str),
+				confidence: detectAccuripConfidence(str),
+			}, nil
+		}
+	}
+
+	return accuripResult{}, nil
+}
+
+// detectCUERipperTOCID detects the TOCID in an Accurip log file generated by CUETools
+func detectCUERipperTOCID(str string) (accuripResult, error) {
+
+	if strings.Contains(str, "CUETools log") {
+		matches := tocIDRegexp.FindStringSubmatch(str)
+		if len(matches) > 0 {
+			return accuripResult{
+				tocID:      matches[1],
+				status:     classifyAccurip(str),
+				confidence: detectAccuripConfidence(str),
+			}, nil
+		}
+	}
+
+	return accuripResult{}, nil
 }
 
-// detectAccuripInFile detects the TOCID in an Accurip log file
-func detectAccuripInFile(logFile string) (string, error) {
-	contents, readErr := os.ReadFile(logFile)
-	if readErr != nil {
-		return "", readErr
-	}
+// classifyAccurip classifies the verification outcome of a log that has a TOCID
+func classifyAccurip(str string) AccuripStatus {
+	str = strings.ToLower(str)
 
-	tocIdFromEAC, eacErr := detectEACTOCID(string(contents))
-	if eacErr != nil {
-		return "", eacErr
-	}
-	if len(tocIdFromEAC) > 0 {
-		return tocIdFromEAC, nil
+	for _, marker := range accuripFailedMarkers {
+		if strings.Contains(str, marker) {
+			return AccuripNotVerified
+		}
 	}
 
-	tocIdFromCueRipper, cueErr := detectCUERipperTOCID(string(contents))
-	if cueErr != nil {
-		return "", cueErr
-	}
-	if len(tocIdFromCueRipper) > 0 {
-		return tocIdFromCueRipper, nil
+	for _, marker := range accuripConfirmedMarkers {
+		if strings.Contains(str, marker) {
+			return AccuripConfirmed
+		}
 	}
 
-	return "", nil
+	return AccuripPresent
 }
 
-// detectAccuripInFile detects the TOCID in an Accurip log file generated by EAC
-func detectEACTOCID(str string) (string, error) {
+// detectAccuripConfidence returns the lowest AccurateRip confidence of the tracks in a log, 0 if none are reported
+func detectAccuripConfidence(str string) int {
+	confidence := 0
 
-	// remove \x00 runes (NULL) as EAC tends to put these in the log file
-	str = strings.Replace(str, "\x00", "", -1)
+	for _, matches := range confidenceRegexp.FindAllStringSubmatch(str, -1) {
+		c, convErr := strconv.Atoi(matches[1])
+		if convErr != nil {
+			continue
+		}
 
-	if strings.Contains(str, "Exact Audio Copy") {
-		if strings.Contains(str, "has been confirmed") {
-			matches := tocIDRegexp.FindStringSubmatch(str)
-			if len(matches) > 0 {
-				tocID := tocIDRegexp.FindStringSubmatch(str)[1]
-				return tocID, nil
-			}
+		if confidence == 0 || c < confidence {
+			confidence = c
 		}
 	}
 
-	return "", nil
+	return confidence
 }
 
-// detectAccuripInFile detects the TOCID in an Accurip log file generated by CUETools
-func detectCUERipperTOCID(str string) (string, error) {
+// crawlFolder crawls a folder of fsys for flac files and accurip logs, root is the real path of fsys used to report file paths
+// and the files found are counted in files
+func crawlFolder(fsys fs.FS, root, dir string, files *fileCounter) (*MusicFolder, error) {
+	var mf *MusicFolder
 
-	if strings.Contains(str, "CUETools log") {
-		matches := tocIDRegexp.FindStringSubmatch(str)
-		if len(matches) > 0 {
-			tocID := tocIDRegexp.FindStringSubmatch(str)[1]
-			return tocID, nil
-		}
-	}
+	// the whole folder is crawled again if a transient error interrupted it
+	crawlErr := retry(func() error {
+		var err error
+		mf, err = crawlFolderOnce(fsys, root, dir, files)
+		return err
+	})
 
-	return "", nil
+	return mf, crawlErr
 }
 
-// crawlFolder crawls a folder for flac files and accurip logs
-func crawlFolder(dir string) (*MusicFolder, error) {
+// crawlFolderOnce makes a single attempt at crawling a folder, the files it counted are taken back if it fails so a retry
+// doesn't count them twice
+func crawlFolderOnce(fsys fs.FS, root, dir string, files *fileCounter) (*MusicFolder, error) {
 	if len(dir) == 0 {
 		return nil, fmt.Errorf("no directory specified")
 	}
 
+	fullPath := filepath.Join(root, filepath.FromSlash(dir))
+
 	// Check if the directory exists
-	info, err := os.Stat(dir)
+	info, err := fs.Stat(fsys, dir)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("directory does not exist: %s", dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("directory does not exist: %s: %w", fullPath, fs.ErrNotExist)
 		} else {
-			return nil, fmt.Errorf("error reading directory: %s", dir)
+			return nil, fmt.Errorf("error reading directory: %s: %w", fullPath, err)
 		}
 	}
 
 	if !info.IsDir() {
-		return nil, fmt.Errorf("the provided path is not a directory: %s", dir)
+		return nil, fmt.Errorf("the provided path is not a directory: %s", fullPath)
 	}
 
 	mf := MusicFolder{
-		Path:       dir,
-		HasAccurip: false,
-		TocID:      "",
-		Files:      []MusicFile{},
-		FileCnt:    0,
-		FlacCnt:    0,
-		TotalBytes: 0,
+		Path:          fullPath,
+		HasAccurip:    false,
+		TocID:         "",
+		AccuripStatus: AccuripNotVerified,
+		Files:         []MusicFile{},
+		FileCnt:       0,
+		FlacCnt:       0,
+		TotalBytes:    0,
 	}
 
 	// loop through the files in the directory
-	walkErr := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+	walkedFiles := int64(0)
+	ownFiles := int64(0)
+	replayGainCnt := int64(0)
+	lintNames := []string{}
+	logs := []logFile{}
+	ig := newIgnorer(fsys)
+	walkErr := fs.WalkDir(fsys, dir, func(fp string, d fs.DirEntry, err error) error {
+		// unreadable sub folders are reported when they're crawled on their own
+		if err != nil && fp != dir && d != nil && d.IsDir() && errors.Is(err, fs.ErrPermission) {
+			return fs.SkipDir
+		}
 		if err != nil {
-			return fmt.Errorf("error walking directory: %s", err)
+			return fmt.Errorf("error walking directory: %w", err)
+		}
+
+		// leave out the files and sub folders listed in .milkdudignore files
+		if fp != dir {
+			skip, ignoreErr := ig.ignored(fp)
+			if ignoreErr != nil {
+				return ignoreErr
+			}
+			if skip && d.IsDir() {
+				return fs.SkipDir
+			}
+			if skip {
+				return nil
+			}
+
+			// leave out what -exclude matches and the files -include doesn't
+			if scanFilters.excluded(fp) || (d.IsDir() && isJunkDir(fp)) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if !d.IsDir() && !scanFilters.included(fp) {
+				return nil
+			}
 		}
 
+		p := filepath.Join(root, filepath.FromSlash(fp))
+
 		if !d.IsDir() {
 
-			ext := strings.Replace(path.Ext(d.Name()), ".", "", -1)
+			// guard against runaway scans, a single folder can hold the whole tree as folders are crawled recursively
+			walkedFiles = walkedFiles + 1
+			foundFiles := files.add(0)
+			if path.Dir(fp) == dir || (dir == "." && !strings.Contains(fp, "/")) {
+				ownFiles = ownFiles + 1
+				foundFiles = files.add(1)
+			}
+			if *flagMaxFiles > 0 && (walkedFiles > *flagMaxFiles || foundFiles > *flagMaxFiles) {
+				return fmt.Errorf("%w: more than %d files found, check the scan path or raise -max-files", errTooManyFiles, *flagMaxFiles)
+			}
+
+			ext := fileTypeOf(d.Name())
 			info, infoErr := d.Info()
 			if infoErr != nil {
-				panic(infoErr)
+				return fmt.Errorf("error reading file info %s: %w", p, infoErr)
+			}
+
+			// use the size of the file a symlink points to rather than the link
+			if *flagFollowSymlinks && d.Type()&fs.ModeSymlink != 0 {
+				info, infoErr = fs.Stat(fsys, fp)
+				if infoErr != nil {
+					return fmt.Errorf("error reading file info %s: %w", p, infoErr)
+				}
+			}
+
+			// extensions mapped with -ext-map are handled by their category instead of their type
+			switch extMap[ext] {
+			case CategoryIgnore:
+				return nil
+
+			case CategoryAudio:
+				mf.TotalBytes = mf.TotalBytes + info.Size()
+				mf.FileCnt = mf.FileCnt + 1
+				mf.OtherAudioCnt = mf.OtherAudioCnt + 1
+				mf.Files = append(mf.Files, MusicFile{
+					Path:     p,
+					Name:     info.Name(),
+					Size:     info.Size(),
+					FileType: ext,
+				})
+				return nil
+
+			case CategoryArt:
+				if *flagImportArt {
+					mf.TotalBytes = mf.TotalBytes + info.Size()
+					mf.FileCnt = mf.FileCnt + 1
+					mf.Files = append(mf.Files, MusicFile{
+						Path:     p,
+						Name:     info.Name(),
+						Size:     info.Size(),
+						FileType: ext,
+					})
+				}
+				return nil
+
+			case CategoryLog:
+				logs = append(logs, logFile{fp, p, info.Name(), info.Size(), info.ModTime(), ext})
+				return nil
 			}
 
-			switch FileType(ext) {
+			switch ext {
 			case FileTypeFlac:
+				if !audioFormats[FileTypeFlac] {
+					break
+				}
+
+				// files without a usable STREAMINFO are counted without a duration
+				fi, flacErr := readFlacInfo(fsys, fp)
+				if flacErr != nil {
+					mf.InvalidFlacFiles = append(mf.InvalidFlacFiles, p)
+				}
+				if fi.replayGain {
+					replayGainCnt = replayGainCnt + 1
+				}
+
 				mf.TotalBytes = mf.TotalBytes + info.Size()
+				mf.TotalDurationSeconds = mf.TotalDurationSeconds + fi.durationSeconds
 				mf.FileCnt = mf.FileCnt + 1
 				mf.FlacCnt = mf.FlacCnt + 1
+				mf.Files = append(mf.Files, MusicFile{
+					Path:            p,
+					Name:            info.Name(),
+					Size:            info.Size(),
+					FileType:        FileTypeFlac,
+					DurationSeconds: fi.durationSeconds,
+				})
+
+			case FileTypeM4a:
+				if !audioFormats[FileTypeM4a] {
+					break
+				}
+
+				// only lossless m4a files are included, lossy and unreadable ones are counted as other audio
+				alac, alacErr := isAlac(fsys, fp)
+				if alacErr != nil {
+					mf.InvalidM4aFiles = append(mf.InvalidM4aFiles, p)
+				}
+
+				if !alac {
+					mf.OtherAudioCnt = mf.OtherAudioCnt + 1
+				} else {
+					mf.TotalBytes = mf.TotalBytes + info.Size()
+					mf.FileCnt = mf.FileCnt + 1
+					mf.AlacCnt = mf.AlacCnt + 1
+					mf.Files = append(mf.Files, MusicFile{
+						Path:     p,
+						Name:     info.Name(),
+						Size:     info.Size(),
+						FileType: FileTypeM4a,
+					})
+				}
+
+			case FileTypeBin, FileTypeImg, FileTypeIso:
+				// disc images are described by a cue sheet and verified by the accurip log like any other rip
+				mf.TotalBytes = mf.TotalBytes + info.Size()
+				mf.FileCnt = mf.FileCnt + 1
+				mf.ImageCnt = mf.ImageCnt + 1
 				mf.Files = append(mf.Files, MusicFile{
 					Path:     p,
 					Name:     info.Name(),
 					Size:     info.Size(),
-					FileType: FileTypeFlac,
+					FileType: FileTypeImage,
 				})
 
-			case FileTypeAccurip:
-				id, accuripErr := detectAccuripInFile(p)
-				if accuripErr != nil {
-					return fmt.Errorf("error reading accurip log file %s: %s", d.Name(), accuripErr)
-				} else {
-					if len(id) > 0 {
-						mf.HasAccurip = true
-						mf.TocID = id
-						mf.TotalBytes = mf.TotalBytes + info.Size()
-						mf.FileCnt = mf.FileCnt + 1
-						mf.Files = append(mf.Files, MusicFile{
-							Path:     p,
-							Name:     info.Name(),
-							Size:     info.Size(),
-							FileType: FileTypeAccurip,
-						})
+			case FileTypeWav:
+				if !audioFormats[FileTypeWav] {
+					break
+				}
+
+				// files without a usable header are counted without a duration
+				duration, _ := wavDuration(fsys, fp)
+
+				mf.TotalBytes = mf.TotalBytes + info.Size()
+				mf.TotalDurationSeconds = mf.TotalDurationSeconds + duration
+				mf.FileCnt = mf.FileCnt + 1
+				mf.WavCnt = mf.WavCnt + 1
+				mf.Files = append(mf.Files, MusicFile{
+					Path:            p,
+					Name:            info.Name(),
+					Size:            info.Size(),
+					FileType:        FileTypeWav,
+					DurationSeconds: duration,
+				})
+
+			case FileTypeAccurip, FileTypeLog, FileTypeLogGz:
+				if ext != FileTypeAccurip && path.Dir(fp) == dir {
+					lintNames = append(lintNames, d.Name())
+				}
+
+				// the logs are parsed together once the folder has been walked
+				logs = append(logs, logFile{fp, p, info.Name(), info.Size(), info.ModTime(), ext})
+
+			case FileTypeCue:
+				if path.Dir(fp) == dir {
+					lintNames = append(lintNames, d.Name())
+				}
+
+				contents, readErr := fs.ReadFile(fsys, fp)
+				if readErr != nil {
+					return fmt.Errorf("error reading cue sheet %s: %w", d.Name(), readErr)
+				}
+
+				cs := parseCue(contents)
+				mf.CueTrackCnt = mf.CueTrackCnt + int64(cs.trackCnt)
+				if len(cs.files) == 1 && cs.trackCnt > 1 {
+					mf.CueImage = true
+				}
+
+				for _, name := range cs.files {
+					if _, statErr := fs.Stat(fsys, path.Join(path.Dir(fp), name)); statErr != nil {
+						mf.MissingCueFiles = append(mf.MissingCueFiles, filepath.Join(filepath.Dir(p), name))
 					}
 				}
 
-			case FileTypeLog:
-				id, accuripErr := detectAccuripInFile(p)
-				if accuripErr != nil {
-					return fmt.Errorf("error reading accurip log file %s: %s", d.Name(), accuripErr)
-				} else {
-					if len(id) > 0 {
-						mf.HasAccurip = true
-						mf.TocID = id
-						mf.TotalBytes = mf.TotalBytes + info.Size()
-						mf.FileCnt = mf.FileCnt + 1
-						mf.Files = append(mf.Files, MusicFile{
-							Path:     p,
-							Name:     info.Name(),
-							Size:     info.Size(),
-							FileType: FileTypeLog,
-						})
+				mf.TotalBytes = mf.TotalBytes + info.Size()
+				mf.FileCnt = mf.FileCnt + 1
+				mf.Files = append(mf.Files, MusicFile{
+					Path:     p,
+					Name:     info.Name(),
+					Size:     info.Size(),
+					FileType: FileTypeCue,
+				})
+
+			case FileTypeZip:
+				if *flagScanZip {
+					zipErr := crawlZip(fsys, fp, &mf)
+					if zipErr != nil {
+						return fmt.Errorf("error reading zip file %s: %w", d.Name(), zipErr)
 					}
 				}
 
@@ -466,6 +2161,18 @@ func crawlFolder(dir string) (*MusicFolder, error) {
 					})
 				}
 
+			case FileTypePng:
+				if *flagImportArt {
+					mf.TotalBytes = mf.TotalBytes + info.Size()
+					mf.FileCnt = mf.FileCnt + 1
+					mf.Files = append(mf.Files, MusicFile{
+						Path:     p,
+						Name:     info.Name(),
+						Size:     info.Size(),
+						FileType: FileTypePng,
+					})
+				}
+
 			default:
 				// log.Println("ignoring file:", d.Name())
 			}
@@ -475,20 +2182,60 @@ func crawlFolder(dir string) (*MusicFolder, error) {
 	})
 
 	if walkErr != nil {
-		return nil, fmt.Errorf("error walking directory: %s", walkErr)
+		files.add(-ownFiles)
+		return nil, fmt.Errorf("error walking directory: %w", walkErr)
+	}
+
+	results, logsErr := parseLogs(fsys, logs)
+	if logsErr != nil {
+		files.add(-ownFiles)
+		return nil, fmt.Errorf("error walking directory: %w", logsErr)
+	}
+
+	for i, lf := range logs {
+		mf.addAccurip(results[i])
+		if results[i].verified() || *flagIncludeLogs {
+			mf.TotalBytes = mf.TotalBytes + lf.size
+			mf.FileCnt = mf.FileCnt + 1
+			mf.Files = append(mf.Files, MusicFile{
+				Path:     lf.path,
+				Name:     lf.name,
+				Size:     lf.size,
+				FileType: lf.fileType,
+			})
+		}
+	}
+
+	// list numbered tracks in order ex: track2 before track10
+	sort.SliceStable(mf.Files, func(i, j int) bool {
+		return natsort.Less(mf.Files[i].Path, mf.Files[j].Path)
+	})
+
+	// an album has ReplayGain applied when every flac track is tagged
+	mf.HasReplayGain = mf.FlacCnt > 0 && replayGainCnt == mf.FlacCnt
+
+	if *flagLint {
+		mf.lintNames(lintNames)
 	}
 
 	return &mf, nil
 }
 
-// crawlBeetsDB crawls folders based on albums from the beets database
-func crawlBeetsDB(beetsDB string, scanResults chan<- scanResult) error {
+// crawlBeetsDB crawls folders based on albums from the beets database on a pool of workers
+// When attrKey is set only the albums with that flexible attribute value are crawled.
+func crawlBeetsDB(beetsDB, scanPath string, attrKey, attrValue string, workers int, files *fileCounter, scanResults chan<- scanResult) error {
 	bdb, beetsErr := beets.New(beetsDB)
 	if beetsErr != nil {
 		return beetsErr
 	}
 
-	albums, albumsErr := bdb.GetAllAlbums()
+	var albums []beets.AlbumSummary
+	var albumsErr error
+	if len(attrKey) > 0 {
+		albums, albumsErr = bdb.GetAlbumsByAttribute(attrKey, attrValue)
+	} else {
+		albums, albumsErr = bdb.GetAllAlbums()
+	}
 	if albumsErr != nil {
 		return albumsErr
 	}
@@ -497,50 +2244,325 @@ func crawlBeetsDB(beetsDB string, scanResults chan<- scanResult) error {
 		return fmt.Errorf("no albums found in beets database")
 	}
 
-	for _, album := range albums {
-		album, albumErr := bdb.GetAlbum(album.ID)
-		if albumErr != nil {
-			panic(albumErr)
+	var mb musicbrainz.MusicBrainz
+	if *flagMusicBrainz {
+		cacheDir, cacheErr := os.UserCacheDir()
+		if cacheErr != nil {
+			return cacheErr
+		}
+
+		var mbErr error
+		mb, mbErr = musicbrainz.New(filepath.Join(cacheDir, "milkdud", "musicbrainz"))
+		if mbErr != nil {
+			return mbErr
 		}
+	}
+
+	c := make(chan beets.AlbumSummary)
+
+	// fetch the albums and crawl their folders on a pool of workers, the beets queries are read only
+	// so they can run on separate connections
+	worker := func(wg *sync.WaitGroup) {
+		for summary := range c {
+			album, albumErr := bdb.GetAlbum(summary.ID)
+			if albumErr != nil {
+				scanResults <- scanResult{
+					nil,
+					fmt.Errorf("error reading beets album %s - %s: %s", summary.Artist, summary.Title, albumErr),
+				}
+				continue
+			}
+
+			// a stale beets database can point at albums that have since been moved or deleted
+			if _, statErr := os.Stat(album.Path); errors.Is(statErr, fs.ErrNotExist) {
+				scanResults <- scanResult{
+					nil,
+					fmt.Errorf("%w: %s - %s (%s)", errAlbumFolderMissing, summary.Artist, summary.Title, album.Path),
+				}
+				continue
+			}
+
+			mf, crawlErr := crawlPath(scanPath, album.Path, files)
+			if crawlErr == nil {
+				mf.ExpectedTrackCnt = int64(album.ItemCount)
+				mf.Artist = album.Artist
+				mf.Title = album.Title
+				mf.Genre = summary.Genre
+
+				// prefer the album artist so compilations are grouped under one artist
+				if len(summary.Artist) > 0 {
+					mf.Artist = summary.Artist
+				}
+				compareTracks(mf, album)
+
+				// release details are optional so lookup failures (ex: offline) are ignored
+				if mb != nil && len(album.AlbumID) > 0 {
+					mf.Release, _ = mb.GetRelease(album.AlbumID)
+				}
+			}
 
-		mf, crawlErr := crawlFolder(album.Path)
-		scanResults <- scanResult{
-			mf,
-			crawlErr,
+			scanResults <- scanResult{
+				mf,
+				crawlErr,
+			}
 		}
+		wg.Done()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go worker(&wg)
+	}
 
+	for _, album := range albums {
+		c <- album
 	}
+	close(c)
+
+	wg.Wait()
 
 	return nil
 }
 
-// crawlFs crawls folders based on albums from the supplied path
-func crawlFs(scanPath string, scanResults chan<- scanResult) error {
+// compareTracks records the tracks beets knows about that are missing on disk and the audio files beets doesn't know about
+func compareTracks(mf *MusicFolder, album *beets.Album) {
+	onDisk := map[string]bool{}
+	for _, file := range mf.Files {
+		if file.FileType.IsAudio() {
+			onDisk[file.Path] = true
+		}
+	}
+
+	inBeets := map[string]bool{}
+	for _, track := range album.Tracks {
+		inBeets[track.Path] = true
+		if !onDisk[track.Path] {
+			mf.MissingTracks = append(mf.MissingTracks, track.Path)
+		}
+	}
+
+	for _, file := range mf.Files {
+		if file.FileType.IsAudio() && !inBeets[file.Path] {
+			mf.UntrackedFiles = append(mf.UntrackedFiles, file.Path)
+		}
+	}
+}
+
+// crawlFs crawls folders of fsys based on albums, scanPath is the real path of fsys used to report folder paths.
+// The folders found by the walk are crawled on a pool of workers.
+func crawlFs(fsys fs.FS, scanPath string, workers, sampleSize int, files *fileCounter, scanResults chan<- scanResult) (int64, error) {
 
-	_, err := os.Stat(scanPath)
+	_, err := fs.Stat(fsys, ".")
 	if os.IsNotExist(err) || len(scanPath) == 0 {
-		return err
+		return 0, err
+	}
+
+	folders := make(chan string)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fp := range folders {
+				if scanCheckpoint != nil {
+					if mf, found := scanCheckpoint.restore(filepath.Join(scanPath, filepath.FromSlash(fp))); found {
+						scanResults <- scanResult{mf, nil}
+						continue
+					}
+				}
+
+				crawl := crawlFolder
+				if isZipAlbum(fp) {
+					crawl = crawlZipAlbum
+				}
+
+				mf, crawlErr := crawl(fsys, scanPath, fp, files)
+				if crawlErr == nil && scanCheckpoint != nil {
+					scanCheckpoint.done(mf)
+				}
+				scanResults <- scanResult{
+					mf,
+					crawlErr,
+				}
+			}
+		}()
 	}
 
-	return filepath.WalkDir(scanPath, func(p string, di fs.DirEntry, err error) error {
+	// when sampling the folders are only listed during the walk and a random subset is crawled after it
+	candidates := []string{}
+
+	// real paths of the directories walked when following symlinks
+	visited := map[string]bool{}
+
+	ig := newIgnorer(fsys)
+
+	var walkFn fs.WalkDirFunc
+	walkFn = func(fp string, di fs.DirEntry, err error) error {
 		if err != nil {
-			return err
-		}
+			// nothing can be scanned if the scan path itself can't be read
+			if fp == "." {
+				return err
+			}
 
-		// skip the rest of the path if we've exceeded the max depth
-		if di.IsDir() && strings.Count(p, string(os.PathSeparator)) > maxDepth {
-			fmt.Println("skipping", p, ", exceeded max depth of", maxDepth, "directories")
+			// skip unreadable folders (ex: permission denied) and carry on with the rest of the tree, the
+			// error of a folder was already recorded when the folder itself was crawled
+			if !isTransient(err) || di == nil || !di.IsDir() {
+				if di != nil && di.IsDir() {
+					return fs.SkipDir
+				}
+				scanResults <- scanResult{
+					nil,
+					fmt.Errorf("error reading %s: %w", filepath.Join(scanPath, filepath.FromSlash(fp)), err),
+				}
+				return nil
+			}
+
+			// the directory itself was already crawled, retry reading it to walk its sub folders
+			var entries []fs.DirEntry
+			readErr := retry(func() error {
+				var err error
+				entries, err = fs.ReadDir(fsys, fp)
+				return err
+			})
+			if readErr != nil {
+				scanResults <- scanResult{
+					nil,
+					fmt.Errorf("error reading directory %s: %w", filepath.Join(scanPath, filepath.FromSlash(fp)), readErr),
+				}
+				return fs.SkipDir
+			}
+
+			for _, entry := range entries {
+				if entry.IsDir() {
+					if subErr := fs.WalkDir(fsys, path.Join(fp, entry.Name()), walkFn); subErr != nil {
+						return subErr
+					}
+				}
+			}
 			return fs.SkipDir
 		}
 
-		if di.IsDir() && p != scanPath {
-			mf, crawlErr := crawlFolder(p)
+		p := filepath.Join(scanPath, filepath.FromSlash(fp))
+
+		if *flagFollowSymlinks {
+			// a symlinked directory is walked as if it were the directory it points to
+			if di.Type()&fs.ModeSymlink != 0 {
+				info, statErr := fs.Stat(fsys, fp)
+				if statErr != nil || !info.IsDir() {
+					return nil
+				}
+				return fs.WalkDir(fsys, fp, walkFn)
+			}
+
+			// symlinks can form cycles so each real directory is only walked once
+			if di.IsDir() {
+				realPath, realErr := filepath.EvalSymlinks(p)
+				if realErr != nil {
+					return realErr
+				}
+				if visited[realPath] {
+					return fs.SkipDir
+				}
+				visited[realPath] = true
+			}
+		}
+
+		// zip archives are crawled as album folders of their own
+		if !di.IsDir() && isZipAlbum(fp) {
+			skip, ignoreErr := ig.ignored(fp)
+			if ignoreErr != nil {
+				return ignoreErr
+			}
+			if skip || scanFilters.excluded(fp) || !scanFilters.included(fp) {
+				return nil
+			}
+
+			if sampleSize > 0 {
+				candidates = append(candidates, fp)
+			} else {
+				folders <- fp
+			}
+			return nil
+		}
+
+		// ignored folders aren't crawled on their own either
+		if di.IsDir() {
+			skip, ignoreErr := ig.ignored(fp)
+			if ignoreErr != nil {
+				return ignoreErr
+			}
+			if skip || scanFilters.excluded(fp) {
+				return fs.SkipDir
+			}
+
+			if isJunkDir(fp) {
+				scanResults <- scanResult{
+					nil,
+					fmt.Errorf("%w: %s", errJunkDir, filepath.Join(scanPath, filepath.FromSlash(fp))),
+				}
+				return fs.SkipDir
+			}
+		}
+
+		// skip the rest of the path if we've exceeded the max depth
+		if di.IsDir() && *flagMaxDepth > 0 && folderDepth(fp) > *flagMaxDepth {
 			scanResults <- scanResult{
-				mf,
-				crawlErr,
+				nil,
+				depthExceededError{p},
 			}
+			return fs.SkipDir
+		}
+
+		if di.IsDir() && fp != "." && sampleSize > 0 {
+			candidates = append(candidates, fp)
+		} else if di.IsDir() && fp != "." {
+			folders <- fp
 		}
 
 		return nil
-	})
+	}
+
+	walkErr := fs.WalkDir(fsys, ".", walkFn)
+	if walkErr == nil {
+		for _, fp := range sampleFolders(candidates, sampleSize) {
+			folders <- fp
+		}
+	}
+
+	// the folders already handed out are still reported
+	close(folders)
+	wg.Wait()
+
+	if walkErr != nil {
+		return 0, walkErr
+	}
+
+	return int64(len(candidates)), nil
+}
+
+// crawlPath crawls a folder on the real filesystem. When the folder is below root the .milkdudignore files and the
+// -include and -exclude patterns apply from root down, like they do when root is scanned.
+func crawlPath(root, dir string, files *fileCounter) (*MusicFolder, error) {
+	if !isWithin(root, dir) || filepath.Clean(root) == filepath.Clean(dir) {
+		return crawlFolder(throttleScan(os.DirFS(dir)), dir, ".", files)
+	}
+
+	rel, relErr := filepath.Rel(root, dir)
+	if relErr != nil {
+		return nil, fmt.Errorf("error reading directory: %s: %w", dir, relErr)
+	}
+
+	fsys := throttleScan(os.DirFS(root))
+	fp := filepath.ToSlash(rel)
+
+	skip, ignoreErr := newIgnorer(fsys).ignored(fp)
+	if ignoreErr != nil {
+		return nil, ignoreErr
+	}
+	if skip || scanFilters.excludedFolder(fp) {
+		return nil, fmt.Errorf("%w: %s", errIgnoredDir, dir)
+	}
+
+	return crawlFolder(fsys, root, fp, files)
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// albumSizeBuckets are the upper bounds in bytes of the album size histogram, 10 MB to ~5 GB
+var albumSizeBuckets = prometheus.ExponentialBuckets(10e6, 2, 10)
+
+var (
+	albumsDesc        = prometheus.NewDesc("milkdud_albums", "Number of albums included in the scan.", nil, nil)
+	accuripAlbumsDesc = prometheus.NewDesc("milkdud_accurip_albums", "Number of albums with an accurip log.", nil, nil)
+	bytesDesc         = prometheus.NewDesc("milkdud_bytes", "Total size in bytes of the included files.", nil, nil)
+	flacFilesDesc     = prometheus.NewDesc("milkdud_flac_files", "Number of flac files.", nil, nil)
+	errorsDesc        = prometheus.NewDesc("milkdud_errors", "Number of folders that failed to scan.", nil, nil)
+	albumSizeDesc     = prometheus.NewDesc("milkdud_album_size_bytes", "Size of the included albums in bytes.", nil, nil)
+)
+
+// scanMetrics is a prometheus collector reporting the latest scan results
+type scanMetrics struct {
+	mu         sync.Mutex
+	stats      Stats
+	albumSizes []int64
+}
+
+// startMetrics serves the prometheus metrics of the scan on addr in the background
+func startMetrics(addr string) (*scanMetrics, error) {
+	m := &scanMetrics{}
+
+	registry := prometheus.NewRegistry()
+	if registerErr := registry.Register(m); registerErr != nil {
+		return nil, fmt.Errorf("error registering metrics: %s", registerErr)
+	}
+
+	// listen up front so a bad address is reported before scanning
+	ln, listenErr := net.Listen("tcp", addr)
+	if listenErr != nil {
+		return nil, fmt.Errorf("error serving metrics: %s", listenErr)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	go http.Serve(ln, mux)
+
+	return m, nil
+}
+
+// update replaces the reported scan results
+func (m *scanMetrics) update(stats Stats, albumSizes []int64) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats = stats
+	m.albumSizes = append([]int64{}, albumSizes...)
+}
+
+// Describe implements prometheus.Collector
+func (m *scanMetrics) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(m, ch)
+}
+
+// Collect implements prometheus.Collector
+func (m *scanMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(albumsDesc, prometheus.GaugeValue, float64(m.stats.FolderCnt))
+	ch <- prometheus.MustNewConstMetric(accuripAlbumsDesc, prometheus.GaugeValue, float64(m.stats.AccuripFolderCnt))
+	ch <- prometheus.MustNewConstMetric(bytesDesc, prometheus.GaugeValue, float64(m.stats.TotalFileSizeBytes))
+	ch <- prometheus.MustNewConstMetric(flacFilesDesc, prometheus.GaugeValue, float64(m.stats.TotalFlacFiles))
+	ch <- prometheus.MustNewConstMetric(errorsDesc, prometheus.GaugeValue, float64(m.stats.Errors))
+
+	// the histogram is rebuilt from the current albums so rescanned albums aren't counted twice
+	sizes := append([]int64{}, m.albumSizes...)
+	sort.Slice(sizes, func(i, j int) bool {
+		return sizes[i] < sizes[j]
+	})
+
+	buckets := map[float64]uint64{}
+	sum := 0.0
+	i := 0
+	for _, bound := range albumSizeBuckets {
+		for i < len(sizes) && float64(sizes[i]) <= bound {
+			i++
+		}
+		buckets[bound] = uint64(i)
+	}
+	for _, size := range sizes {
+		sum = sum + float64(size)
+	}
+
+	ch <- prometheus.MustNewConstHistogram(albumSizeDesc, uint64(len(sizes)), sum, buckets)
+}
+
+// wait keeps the metrics served until interrupted
+func (m *scanMetrics) wait(addr string) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+	fmt.Println("Serving metrics on", addr, "press Ctrl-C to exit")
+	<-interrupt
+	fmt.Println("Stopped serving metrics on", addr)
+}
+
+// folderSizes returns the total bytes of each music folder
+func folderSizes(folders map[string]*MusicFolder) []int64 {
+	sizes := make([]int64, 0, len(folders))
+	for _, folder := range folders {
+		sizes = append(sizes, folder.TotalBytes)
+	}
+
+	return sizes
+}
@@ -0,0 +1,156 @@
+package musicbrainz
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	// releaseURL is the MusicBrainz web service release lookup URL
+	releaseURL = "https://musicbrainz.org/ws/2/release/%s?inc=labels&fmt=json"
+
+	// userAgent identifies milkdud to MusicBrainz as required by their API terms
+	userAgent = "milkdud ( https://github.com/concretelabs/milkdud )"
+
+	// requestInterval is the minimum time between requests allowed by MusicBrainz
+	requestInterval = time.Second
+)
+
+// mbidRegexp matches a valid MusicBrainz ID
+var mbidRegexp = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// Release represents the details of a MusicBrainz release
+type Release struct {
+	ID            string `json:"id"`
+	Title         string `json:"title"`
+	Date          string `json:"date,omitempty"`
+	Country       string `json:"country,omitempty"`
+	Label         string `json:"label,omitempty"`
+	CatalogNumber string `json:"catalog_number,omitempty"`
+}
+
+// release is the subset of the MusicBrainz release response used by milkdud
+type release struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Date      string `json:"date"`
+	Country   string `json:"country"`
+	LabelInfo []struct {
+		CatalogNumber string `json:"catalog-number"`
+		Label         struct {
+			Name string `json:"name"`
+		} `json:"label"`
+	} `json:"label-info"`
+}
+
+// MusicBrainz interface for MusicBrainz web service access
+type MusicBrainz interface {
+	GetRelease(mbid string) (*Release, error)
+}
+
+// musicBrainz is the implementation of the MusicBrainz interface
+type musicBrainz struct {
+	cacheDir    string
+	client      *http.Client
+	mu          sync.Mutex
+	lastRequest time.Time
+}
+
+// GetRelease looks up a release by MusicBrainz ID, using the disk cache when possible
+func (mb *musicBrainz) GetRelease(mbid string) (*Release, error) {
+	if !mbidRegexp.MatchString(mbid) {
+		return nil, fmt.Errorf("invalid MusicBrainz ID %s", mbid)
+	}
+
+	cacheFile := filepath.Join(mb.cacheDir, mbid+".json")
+
+	contents, readErr := os.ReadFile(cacheFile)
+	if readErr != nil {
+		var fetchErr error
+		contents, fetchErr = mb.fetch(fmt.Sprintf(releaseURL, mbid))
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+
+		if writeErr := os.WriteFile(cacheFile, contents, 0644); writeErr != nil {
+			return nil, fmt.Errorf("error caching MusicBrainz release %s", writeErr)
+		}
+	}
+
+	r := release{}
+	if jsonErr := json.Unmarshal(contents, &r); jsonErr != nil {
+		return nil, fmt.Errorf("error parsing MusicBrainz release %s", jsonErr)
+	}
+
+	rel := Release{
+		ID:      r.ID,
+		Title:   r.Title,
+		Date:    r.Date,
+		Country: r.Country,
+	}
+
+	if len(r.LabelInfo) > 0 {
+		rel.Label = r.LabelInfo[0].Label.Name
+		rel.CatalogNumber = r.LabelInfo[0].CatalogNumber
+	}
+
+	return &rel, nil
+}
+
+// fetch requests a URL from the MusicBrainz web service, waiting to respect the rate limit
+func (mb *musicBrainz) fetch(url string) ([]byte, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if wait := requestInterval - time.Since(mb.lastRequest); wait > 0 {
+		time.Sleep(wait)
+	}
+	mb.lastRequest = time.Now()
+
+	req, reqErr := http.NewRequest(http.MethodGet, url, nil)
+	if reqErr != nil {
+		return nil, fmt.Errorf("error creating MusicBrainz request %s", reqErr)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, respErr := mb.client.Do(req)
+	if respErr != nil {
+		return nil, fmt.Errorf("error requesting MusicBrainz release %s", respErr)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error requesting MusicBrainz release %s", resp.Status)
+	}
+
+	body, bodyErr := io.ReadAll(resp.Body)
+	if bodyErr != nil {
+		return nil, fmt.Errorf("error reading MusicBrainz release %s", bodyErr)
+	}
+
+	return body, nil
+}
+
+// New creates a new MusicBrainz client that caches responses in cacheDir
+func New(cacheDir string) (MusicBrainz, error) {
+	if cacheDir == "" {
+		return nil, fmt.Errorf("MusicBrainz cache directory is required")
+	}
+
+	if mkdirErr := os.MkdirAll(cacheDir, 0755); mkdirErr != nil {
+		return nil, fmt.Errorf("error creating MusicBrainz cache directory %s", mkdirErr)
+	}
+
+	return &musicBrainz{
+		cacheDir: cacheDir,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// nameData are the variables available to the torrent name template. The counts are the scan stats, the torrent only
+// filters like -torrent-exclude and -torrent-only-accurip aren't applied to them.
+type nameData struct {
+	Artist       string // album artist from beets, only set when the torrent holds a single album
+	Album        string // album title from beets or the folder name, only set when the torrent holds a single album
+	TocID        string // CTDB TOCID, only set when the torrent holds a single album
+	AlbumCount   int64  // albums counted in the scan stats
+	AccuripCount int64  // albums with an accurip log
+	TotalFiles   int64  // files counted in the scan stats
+	TotalSize    string // human readable size of the files
+	Date         string // date the torrent was created as YYYY-MM-DD
+	Library      string // name of the scanned library folder
+	Tags         string // tags from -g
+}
+
+// parseNameTemplate parses the torrent name template, checking it only uses known variables. It returns nil
+// when no template is set.
+func parseNameTemplate(s string) (*template.Template, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	tmpl, parseErr := template.New("name").Parse(s)
+	if parseErr != nil {
+		return nil, fmt.Errorf("invalid name template: %s", parseErr)
+	}
+
+	// unknown fields are only reported when the template is executed
+	if execErr := tmpl.Execute(io.Discard, nameData{}); execErr != nil {
+		return nil, fmt.Errorf("invalid name template: %s", execErr)
+	}
+
+	return tmpl, nil
+}
+
+// torrentName builds the torrent file name from the template, the scan stats and the albums of the torrent
+func torrentName(tmpl *template.Template, stats Stats, albums []MusicFolder, tags string) (string, error) {
+	data := nameData{
+		AlbumCount:   stats.FolderCnt,
+		AccuripCount: stats.AccuripFolderCnt,
+		TotalFiles:   stats.TotalFiles,
+		TotalSize:    stats.TotalFileSize,
+		Date:         time.Now().Format("2006-01-02"),
+		Library:      filepath.Base(stats.Path),
+		Tags:         tags,
+	}
+
+	if len(albums) == 1 {
+		data.Artist = albums[0].Artist
+		data.Album = albums[0].Title
+		if len(data.Album) == 0 {
+			data.Album = filepath.Base(albums[0].Path)
+		}
+		data.TocID = albums[0].TocID
+	}
+
+	var b strings.Builder
+	if execErr := tmpl.Execute(&b, data); execErr != nil {
+		return "", fmt.Errorf("error building torrent name: %s", execErr)
+	}
+
+	if len(strings.TrimSpace(b.String())) == 0 {
+		return "", fmt.Errorf("error building torrent name: the name template produced an empty name")
+	}
+
+	return sanitizeFileName(b.String()), nil
+}
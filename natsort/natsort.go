@@ -0,0 +1,51 @@
+package natsort
+
+import (
+	"strings"
+)
+
+// Less compares two strings treating runs of digits as numbers so "track2" sorts before "track10"
+func Less(a, b string) bool {
+	for len(a) > 0 && len(b) > 0 {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			na, restA := splitDigits(a)
+			nb, restB := splitDigits(b)
+
+			// compare the numbers ignoring leading zeros
+			ta, tb := strings.TrimLeft(na, "0"), strings.TrimLeft(nb, "0")
+			if len(ta) != len(tb) {
+				return len(ta) < len(tb)
+			}
+			if ta != tb {
+				return ta < tb
+			}
+			if na != nb {
+				return len(na) < len(nb)
+			}
+
+			a, b = restA, restB
+			continue
+		}
+
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+
+		a, b = a[1:], b[1:]
+	}
+
+	return len(a) < len(b)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// splitDigits splits the leading run of digits from s
+func splitDigits(s string) (string, string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
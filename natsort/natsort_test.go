@@ -0,0 +1,50 @@
+package natsort
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLessTracks(t *testing.T) {
+	want := []string{}
+	for i := 1; i <= 12; i++ {
+		want = append(want, fmt.Sprintf("Album/Track %d.flac", i))
+	}
+
+	// a plain string sort puts track 10 to 12 before track 2
+	got := append([]string{}, want...)
+	sort.Strings(got)
+	sort.SliceStable(got, func(i, j int) bool {
+		return Less(got[i], got[j])
+	})
+
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("sorted = %v, want %v", got, want)
+	}
+}
+
+func TestLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"track2", "track10", true},
+		{"track10", "track2", false},
+		{"track02", "track10", true},
+		{"track2", "track02", true}, // equal numbers sort the shorter one first
+		{"track02", "track2", false},
+		{"Disc 1/12.flac", "Disc 2/1.flac", true},
+		{"a", "b", true},
+		{"a", "a", false},
+		{"a", "a1", true},
+		{"", "a", true},
+	}
+
+	for _, tt := range tests {
+		if got := Less(tt.a, tt.b); got != tt.want {
+			t.Errorf("Less(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
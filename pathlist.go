@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// readPathList reads the folders listed in fileName, or stdin for -. The list is NUL seperated if it contains a NUL
+// (ex: find -print0), otherwise one folder per line with blank lines and lines starting with # skipped.
+func readPathList(fileName string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if fileName != "-" {
+		f, openErr := os.Open(fileName)
+		if openErr != nil {
+			return nil, fmt.Errorf("error reading path list: %s", openErr)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	contents, readErr := io.ReadAll(r)
+	if readErr != nil {
+		return nil, fmt.Errorf("error reading path list %s: %s", fileName, readErr)
+	}
+
+	var lines []string
+	if bytes.IndexByte(contents, 0) >= 0 {
+		lines = strings.Split(string(contents), "\x00")
+	} else {
+		for _, line := range strings.Split(string(contents), "\n") {
+			line = strings.TrimSpace(line)
+			if !strings.HasPrefix(line, "#") {
+				lines = append(lines, line)
+			}
+		}
+	}
+
+	dirs := []string{}
+	seen := map[string]bool{}
+
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+
+		dir, absErr := filepath.Abs(line)
+		if absErr != nil {
+			return nil, fmt.Errorf("error reading path list %s: %s", fileName, absErr)
+		}
+
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no folders listed in %s", fileName)
+	}
+
+	return dirs, nil
+}
+
+// crawlPathList crawls each listed folder as an album, the files of its subfolders are included like in a scan but the
+// subfolders aren't reported on their own unless they're listed too. A listed path that isn't a folder is reported as
+// an error of that folder. The ignore files from root down apply to each folder.
+func crawlPathList(root string, dirs []string, workers int, files *fileCounter, scanResults chan<- scanResult) {
+	folders := make(chan string)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dir := range folders {
+				info, statErr := os.Stat(dir)
+				if statErr == nil && !info.IsDir() {
+					statErr = fmt.Errorf("listed path is not a directory: %s", dir)
+				}
+				if statErr != nil {
+					scanResults <- scanResult{nil, statErr}
+					continue
+				}
+
+				mf, crawlErr := crawlPath(root, dir, files)
+				scanResults <- scanResult{mf, crawlErr}
+			}
+		}()
+	}
+
+	for _, dir := range dirs {
+		folders <- dir
+	}
+	close(folders)
+	wg.Wait()
+}
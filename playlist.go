@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"concretelabs/milkdud/natsort"
+)
+
+// writePlaylist writes an extended M3U playlist of the audio files directly inside a music folder.
+// An existing playlist is left untouched. It returns the playlist path or an empty string if the
+// folder has no tracks.
+func writePlaylist(mf *MusicFolder) (string, error) {
+	tracks := []MusicFile{}
+	for _, file := range mf.Files {
+		if file.FileType.IsAudio() && filepath.Dir(file.Path) == mf.Path {
+			tracks = append(tracks, file)
+		}
+	}
+
+	if len(tracks) == 0 {
+		return "", nil
+	}
+
+	// skip folders that already have a playlist
+	existing, globErr := filepath.Glob(filepath.Join(mf.Path, "*.m3u*"))
+	if globErr != nil {
+		return "", fmt.Errorf("error looking for playlists in %s: %s", mf.Path, globErr)
+	}
+	if len(existing) > 0 {
+		return existing[0], nil
+	}
+
+	sort.Slice(tracks, func(i, j int) bool {
+		return natsort.Less(tracks[i].Name, tracks[j].Name)
+	})
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	for _, track := range tracks {
+		title := strings.TrimSuffix(track.Name, filepath.Ext(track.Name))
+		fmt.Fprintf(&b, "#EXTINF:%d,%s\n", int64(math.Round(track.DurationSeconds)), title)
+		b.WriteString(track.Name + "\n")
+	}
+
+	p := filepath.Join(mf.Path, filepath.Base(mf.Path)+".m3u")
+	if writeErr := os.WriteFile(p, []byte(b.String()), 0644); writeErr != nil {
+		return "", fmt.Errorf("error writing playlist %s: %s", p, writeErr)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// PathProblem is a file of the torrent that changed since it was scanned
+type PathProblem struct {
+	Path         string `json:"path"`
+	Problem      string `json:"problem"` // missing, size_changed or unreadable
+	ExpectedSize int64  `json:"expected_size"`
+	Size         int64  `json:"size,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// verifyPaths stats every file of the torrent and returns all the files that are missing or changed size since the
+// scan, so they can be fixed before hashing instead of hashing failing on the first one. Remote files are read from
+// fsys relative to scanPath.
+func verifyPaths(files []fileData, fsys fs.FS, scanPath string) []PathProblem {
+	problems := []PathProblem{}
+	seen := map[string]bool{}
+
+	for _, file := range files {
+		p := filepath.Join(file.path, file.name)
+
+		// nested album folders list the same files
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+
+		var info fs.FileInfo
+		var statErr error
+		if fsys == nil {
+			info, statErr = os.Stat(p)
+		} else {
+			relPath, relErr := filepath.Rel(scanPath, p)
+			if relErr != nil {
+				statErr = relErr
+			} else {
+				info, statErr = fs.Stat(fsys, filepath.ToSlash(relPath))
+			}
+		}
+
+		switch {
+		case errors.Is(statErr, fs.ErrNotExist):
+			problems = append(problems, PathProblem{Path: p, Problem: "missing", ExpectedSize: file.size})
+		case statErr != nil:
+			problems = append(problems, PathProblem{Path: p, Problem: "unreadable", ExpectedSize: file.size, Error: statErr.Error()})
+		case info.Size() != file.size:
+			problems = append(problems, PathProblem{Path: p, Problem: "size_changed", ExpectedSize: file.size, Size: info.Size()})
+		}
+	}
+
+	return problems
+}
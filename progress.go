@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+const (
+	// progressWidth is the number of characters of the progress bar
+	progressWidth = 30
+
+	// progressInterval is the minimum time between redraws of the progress bar
+	progressInterval = 100 * time.Millisecond
+)
+
+// progressBar draws the scan progress on a single terminal line
+type progressBar struct {
+	out      io.Writer
+	total    int64 // folders expected, 0 if unknown
+	done     int64
+	errors   int64
+	bytes    int64
+	start    time.Time
+	lastDraw time.Time
+}
+
+// newProgressBar creates a progress bar for total folders, 0 if the number of folders isn't known up front
+func newProgressBar(out io.Writer, total int64) *progressBar {
+	return &progressBar{
+		out:   out,
+		total: total,
+		start: time.Now(),
+	}
+}
+
+// add records a scanned folder and redraws the bar when it's due
+func (pb *progressBar) add(folderBytes int64, failed bool) {
+	pb.done = pb.done + 1
+	pb.bytes = pb.bytes + folderBytes
+	if failed {
+		pb.errors = pb.errors + 1
+	}
+
+	if time.Since(pb.lastDraw) >= progressInterval {
+		pb.draw()
+	}
+}
+
+// finish draws the final state of the bar
+func (pb *progressBar) finish() {
+	pb.draw()
+}
+
+// draw writes the bar over the current line
+func (pb *progressBar) draw() {
+	pb.lastDraw = time.Now()
+	elapsed := time.Since(pb.start).Seconds()
+
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(pb.done) / elapsed
+	}
+
+	line := fmt.Sprintf("%d folders", pb.done)
+	eta := ""
+
+	// the pre-count can be off when the library changes during the scan
+	if pb.total > 0 {
+		total := pb.total
+		if pb.done > total {
+			total = pb.done
+		}
+
+		filled := int(progressWidth * pb.done / total)
+		line = fmt.Sprintf("[%s%s] %d/%d folders", strings.Repeat("#", filled), strings.Repeat(".", progressWidth-filled), pb.done, total)
+
+		if rate > 0 {
+			eta = " ETA " + formatDuration(float64(total-pb.done)/rate)
+		}
+	}
+
+	if pb.errors > 0 {
+		line = line + fmt.Sprintf(" %d errors", pb.errors)
+	}
+
+	// \033[K clears what's left of a longer previous line
+	fmt.Fprintf(pb.out, "\r%s %.1f folders/s %s%s\033[K", line, rate, byteCountSI(pb.bytes), eta)
+}
+
+// countFolders counts the folders crawlFs will scan under the root of fsys, for the total of the progress bar
+func countFolders(fsys fs.FS) (int64, error) {
+	var cnt int64
+	ig := newIgnorer(fsys)
+
+	walkErr := fs.WalkDir(fsys, ".", func(fp string, di fs.DirEntry, err error) error {
+		if err != nil {
+			if fp == "." {
+				return err
+			}
+			return nil
+		}
+
+		if !di.IsDir() && isZipAlbum(fp) && !scanFilters.excluded(fp) && scanFilters.included(fp) {
+			if skip, _ := ig.ignored(fp); !skip {
+				cnt = cnt + 1
+			}
+			return nil
+		}
+
+		if !di.IsDir() || fp == "." {
+			return nil
+		}
+
+		skip, ignoreErr := ig.ignored(fp)
+		if ignoreErr != nil {
+			return ignoreErr
+		}
+		if skip || scanFilters.excluded(fp) || isJunkDir(fp) || (*flagMaxDepth > 0 && folderDepth(fp) > *flagMaxDepth) {
+			return fs.SkipDir
+		}
+
+		cnt = cnt + 1
+
+		return nil
+	})
+
+	return cnt, walkErr
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"concretelabs/milkdud/sftpfs"
+	"concretelabs/milkdud/webdavfs"
+)
+
+// remoteUnsupportedFlags are flags that need the library on the local filesystem
+var remoteUnsupportedFlags = []string{"art-max-dimension", "find-dupes", "follow-symlinks", "m3u", "watch"}
+
+// remoteSchemes are the URL schemes of the libraries read over the network
+var remoteSchemes = []string{"sftp://", "webdav://", "webdavs://"}
+
+// isRemoteTarget returns true if the scan path is an sftp://, webdav:// or webdavs:// URL
+func isRemoteTarget(scanPath string) bool {
+	for _, scheme := range remoteSchemes {
+		if strings.HasPrefix(scanPath, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRemoteFlags returns an error if a flag that needs a local library is set
+func checkRemoteFlags() error {
+	return checkIncompatibleFlags("when scanning a remote library", remoteUnsupportedFlags...)
+}
+
+// openRemote connects to an sftp:// or WebDAV scan target and returns its filesystem and the path of the library on
+// the server
+func openRemote(target string) (fs.FS, string, error) {
+	u, parseErr := url.Parse(target)
+	if parseErr != nil {
+		return nil, "", fmt.Errorf("invalid remote URL: %s", parseErr)
+	}
+
+	var fsys fs.FS
+	var remoteErr error
+	if u.Scheme == "sftp" {
+		fsys, remoteErr = sftpfs.New(u)
+	} else {
+		fsys, remoteErr = webdavfs.New(u)
+	}
+	if remoteErr != nil {
+		return nil, "", remoteErr
+	}
+
+	root := u.Path
+	if len(root) == 0 {
+		root = "."
+	}
+
+	return fsys, root, nil
+}
+
+// remoteSub returns the filesystem of the folder root in a remote library at scanPath, the files of a torrent rooted
+// below the scan path are read relative to it
+func remoteSub(fsys fs.FS, scanPath, root string) (fs.FS, error) {
+	rel, relErr := filepath.Rel(scanPath, root)
+	if relErr != nil || rel == "." {
+		return fsys, nil
+	}
+
+	sub, subErr := fs.Sub(fsys, filepath.ToSlash(rel))
+	if subErr != nil {
+		return nil, fmt.Errorf("error reading %s: %s", root, subErr)
+	}
+
+	return sub, nil
+}
+
+// redactRemoteTarget hides the password of a remote scan target so it isn't printed or written to the stats
+func redactRemoteTarget(target string) string {
+	u, parseErr := url.Parse(target)
+	if parseErr != nil {
+		return target
+	}
+
+	return u.Redacted()
+}
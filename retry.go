@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"syscall"
+	"time"
+)
+
+const (
+	// retryAttempts is how many times an operation that failed with a transient error is attempted
+	retryAttempts = 4
+
+	// retryBackoff is the wait before the first retry, doubling for each retry after
+	retryBackoff = 250 * time.Millisecond
+)
+
+// transientErrors are errors network filesystems (ex: SMB, NFS) return for temporary failures
+var transientErrors = []error{
+	syscall.EAGAIN,
+	syscall.EINTR,
+	syscall.EBUSY,
+	syscall.ETIMEDOUT,
+	syscall.ECONNRESET,
+	syscall.EHOSTDOWN,
+}
+
+// isTransient returns true if err is worth retrying
+func isTransient(err error) bool {
+	for _, transient := range transientErrors {
+		if errors.Is(err, transient) {
+			return true
+		}
+	}
+	return false
+}
+
+// retry runs op, retrying with exponential backoff while it fails with a transient error
+func retry(op func() error) error {
+	backoff := retryBackoff
+
+	err := op()
+	for attempt := 1; attempt < retryAttempts && err != nil && isTransient(err); attempt++ {
+		time.Sleep(backoff)
+		backoff = backoff * 2
+		err = op()
+	}
+
+	return err
+}
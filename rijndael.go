@@ -0,0 +1,138 @@
+package main
+
+// rijndael is the Rijndael block cipher with a variable block size, crypto/aes only supports 128 bit blocks.
+// Only encryption is implemented as that's all that is needed to compute log checksums.
+type rijndael struct {
+	nb        int       // block size in 32 bit columns
+	nr        int       // number of rounds
+	roundKeys [][4]byte // expanded key, one column per word
+}
+
+// rijndaelSbox is the substitution box, built in init
+var rijndaelSbox [256]byte
+
+func init() {
+	for x := 0; x < 256; x++ {
+		// the multiplicative inverse in GF(2^8) is x^254
+		inv := byte(1)
+		for i := 0; i < 254; i++ {
+			inv = gfMul(inv, byte(x))
+		}
+		if x == 0 {
+			inv = 0
+		}
+
+		s := inv ^ rotl8(inv, 1) ^ rotl8(inv, 2) ^ rotl8(inv, 3) ^ rotl8(inv, 4) ^ 0x63
+		rijndaelSbox[x] = s
+	}
+}
+
+// newRijndael expands a 128, 192 or 256 bit key for a block size of nb 32 bit columns (4, 6 or 8)
+func newRijndael(key []byte, nb int) *rijndael {
+	nk := len(key) / 4
+	nr := nk + 6
+	if nb > nk {
+		nr = nb + 6
+	}
+
+	words := make([][4]byte, nb*(nr+1))
+	for i := 0; i < nk; i++ {
+		copy(words[i][:], key[4*i:4*i+4])
+	}
+
+	rcon := byte(1)
+	for i := nk; i < len(words); i++ {
+		w := words[i-1]
+		if i%nk == 0 {
+			w = [4]byte{rijndaelSbox[w[1]] ^ rcon, rijndaelSbox[w[2]], rijndaelSbox[w[3]], rijndaelSbox[w[0]]}
+			rcon = gfMul(rcon, 2)
+		} else if nk > 6 && i%nk == 4 {
+			w = [4]byte{rijndaelSbox[w[0]], rijndaelSbox[w[1]], rijndaelSbox[w[2]], rijndaelSbox[w[3]]}
+		}
+		for j := range w {
+			words[i][j] = words[i-nk][j] ^ w[j]
+		}
+	}
+
+	return &rijndael{nb: nb, nr: nr, roundKeys: words}
+}
+
+// blockSize returns the block size in bytes
+func (r *rijndael) blockSize() int {
+	return 4 * r.nb
+}
+
+// encrypt encrypts a single block in place
+func (r *rijndael) encrypt(block []byte) {
+	r.addRoundKey(block, 0)
+	for round := 1; round <= r.nr; round++ {
+		for i := range block {
+			block[i] = rijndaelSbox[block[i]]
+		}
+		r.shiftRows(block)
+		if round != r.nr {
+			r.mixColumns(block)
+		}
+		r.addRoundKey(block, round)
+	}
+}
+
+// addRoundKey xors the round key into the block, the block is stored column by column
+func (r *rijndael) addRoundKey(block []byte, round int) {
+	for c := 0; c < r.nb; c++ {
+		for row := 0; row < 4; row++ {
+			block[4*c+row] ^= r.roundKeys[round*r.nb+c][row]
+		}
+	}
+}
+
+// shiftRows rotates each row left, the offsets depend on the block size
+func (r *rijndael) shiftRows(block []byte) {
+	offsets := [4]int{0, 1, 2, 3}
+	if r.nb == 8 {
+		offsets = [4]int{0, 1, 3, 4}
+	}
+
+	row := make([]byte, r.nb)
+	for i := 1; i < 4; i++ {
+		for c := 0; c < r.nb; c++ {
+			row[c] = block[4*((c+offsets[i])%r.nb)+i]
+		}
+		for c := 0; c < r.nb; c++ {
+			block[4*c+i] = row[c]
+		}
+	}
+}
+
+// mixColumns multiplies each column by the fixed polynomial
+func (r *rijndael) mixColumns(block []byte) {
+	for c := 0; c < r.nb; c++ {
+		a0, a1, a2, a3 := block[4*c], block[4*c+1], block[4*c+2], block[4*c+3]
+		block[4*c] = gfMul(a0, 2) ^ gfMul(a1, 3) ^ a2 ^ a3
+		block[4*c+1] = a0 ^ gfMul(a1, 2) ^ gfMul(a2, 3) ^ a3
+		block[4*c+2] = a0 ^ a1 ^ gfMul(a2, 2) ^ gfMul(a3, 3)
+		block[4*c+3] = gfMul(a0, 3) ^ a1 ^ a2 ^ gfMul(a3, 2)
+	}
+}
+
+// gfMul multiplies two bytes in GF(2^8)
+func gfMul(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+// rotl8 rotates a byte left
+func rotl8(b byte, n int) byte {
+	return b<<n | b>>(8-n)
+}
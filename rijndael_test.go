@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestRijndael(t *testing.T) {
+	// Brian Gladman's Rijndael test vectors for every block and key size used, the 128 bit blocks are AES
+	plaintext := "3243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c8"
+	tests := []struct {
+		key  string
+		nb   int
+		want string
+	}{
+		{"2b7e151628aed2a6abf7158809cf4f3c", 4, "3925841d02dc09fbdc118597196a0b32"},
+		{"2b7e151628aed2a6abf7158809cf4f3c762e7160f38b4da5", 4, "f9fb29aefc384a250340d833b87ebc00"},
+		{"2b7e151628aed2a6abf7158809cf4f3c762e7160f38b4da56a784d9045190cfe", 4, "1a6e6c2c662e7da6501ffb62bc9e93f3"},
+		{"2b7e151628aed2a6abf7158809cf4f3c", 8, "7d15479076b69a46ffb3b3beae97ad8313f622f67fedb487de9f06b9ed9c8f19"},
+		{"2b7e151628aed2a6abf7158809cf4f3c762e7160f38b4da5", 8, "5d7101727bb25781bf6715b0e6955282b9610e23a43c2eb062699f0ebf5887b2"},
+		{"2b7e151628aed2a6abf7158809cf4f3c762e7160f38b4da56a784d9045190cfe", 8, "a49406115dfb30a40418aafa4869b7c6a886ff31602a7dd19c889dc64f7e4e7a"},
+	}
+
+	for _, tt := range tests {
+		key, _ := hex.DecodeString(tt.key)
+		block, _ := hex.DecodeString(plaintext[:8*tt.nb])
+
+		cipher := newRijndael(key, tt.nb)
+		if cipher.blockSize() != len(block) {
+			t.Fatalf("blockSize() = %d, want %d", cipher.blockSize(), len(block))
+		}
+
+		cipher.encrypt(block)
+		if got := hex.EncodeToString(block); got != tt.want {
+			t.Errorf("%d bit key %d bit block = %s, want %s", 8*len(key), 32*tt.nb, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// multiPathUnsupportedFlags are the flags that only work with a single scan path
+var multiPathUnsupportedFlags = []string{"b", "files-from", "paths-from", "watch"}
+
+// checkMultiPathFlags returns an error if several scan paths are given with a flag or target that only takes one
+func checkMultiPathFlags(scanPaths []string) error {
+	if flagsErr := checkIncompatibleFlags("with several scan paths", multiPathUnsupportedFlags...); flagsErr != nil {
+		return flagsErr
+	}
+
+	for _, p := range scanPaths {
+		if isRemoteTarget(p) {
+			return fmt.Errorf("%s: remote targets can't be scanned with other paths", redactRemoteTarget(p))
+		}
+	}
+
+	return nil
+}
+
+// multiplePaths returns the scan paths when there are several, nil for a single one
+func multiplePaths(scanPaths []string) []string {
+	if len(scanPaths) < 2 {
+		return nil
+	}
+	return scanPaths
+}
+
+// isScanRoot returns true if p is one of the scan paths
+func isScanRoot(p string, scanPaths []string) bool {
+	for _, root := range scanPaths {
+		if filepath.Clean(root) == filepath.Clean(p) {
+			return true
+		}
+	}
+	return false
+}
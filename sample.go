@@ -0,0 +1,67 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// sampleUnsupportedFlags are the flags that need every folder to be scanned
+var sampleUnsupportedFlags = []string{"b", "files-from", "find-dupes", "list-files", "m3u", "paths-from", "since", "t", "watch"}
+
+// SampleEstimate is the extrapolation of the stats of a random sample of folders to the whole library
+type SampleEstimate struct {
+	SampledFolders            int64   `json:"sampled_folders"`
+	CandidateFolders          int64   `json:"candidate_folders"`
+	EstimatedAlbumCnt         int64   `json:"estimated_album_count"`
+	EstimatedAccuripFolderCnt int64   `json:"estimated_accurip_folder_count"`
+	EstimatedTotalFiles       int64   `json:"estimated_total_files"`
+	EstimatedTotalFileSize    string  `json:"estimated_total_file_size"`
+	EstimatedTotalBytes       int64   `json:"estimated_total_file_size_bytes"`
+	EstimatedDurationSeconds  float64 `json:"estimated_total_duration_seconds"`
+}
+
+// checkSampleFlags returns an error if a flag that needs a full scan is set with -sample
+func checkSampleFlags() error {
+	return checkIncompatibleFlags("with -sample", sampleUnsupportedFlags...)
+}
+
+// sampleFolders picks n random folders, in the order they were found
+func sampleFolders(folders []string, n int) []string {
+	if n >= len(folders) {
+		return folders
+	}
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	picked := r.Perm(len(folders))[:n]
+	sort.Ints(picked)
+
+	sampled := make([]string, 0, n)
+	for _, i := range picked {
+		sampled = append(sampled, folders[i])
+	}
+
+	return sampled
+}
+
+// estimate extrapolates the stats of the sampled folders to every candidate folder
+func (s *Stats) estimate(candidateCnt int64) {
+	if s.FoldersScanned == 0 {
+		s.Sample = &SampleEstimate{CandidateFolders: candidateCnt}
+		return
+	}
+
+	scale := float64(candidateCnt) / float64(s.FoldersScanned)
+	totalBytes := int64(float64(s.TotalFileSizeBytes) * scale)
+
+	s.Sample = &SampleEstimate{
+		SampledFolders:            s.FoldersScanned,
+		CandidateFolders:          candidateCnt,
+		EstimatedAlbumCnt:         int64(float64(s.FolderCnt) * scale),
+		EstimatedAccuripFolderCnt: int64(float64(s.AccuripFolderCnt) * scale),
+		EstimatedTotalFiles:       int64(float64(s.TotalFiles) * scale),
+		EstimatedTotalFileSize:    byteCountSI(totalBytes),
+		EstimatedTotalBytes:       totalBytes,
+		EstimatedDurationSeconds:  s.TotalDurationSeconds * scale,
+	}
+}
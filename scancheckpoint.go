@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// scanCheckpointInterval is how often the scanned folders are saved to the scan checkpoint file
+const scanCheckpointInterval = 10 * time.Second
+
+// scanCheckpoint is set with -resume, filesystem scans skip the folders it already has
+var scanCheckpoint *scanCheckpointer
+
+// scanCheckpointFolder is a scanned folder saved in a scan checkpoint file
+type scanCheckpointFolder struct {
+	Folder MusicFolder `json:"folder"`
+	TOC    string      `json:"toc,omitempty"` // the unexported TOC of the folder, for -ctdb-verify
+}
+
+// scanCheckpointData is what's saved in a scan checkpoint file
+type scanCheckpointData struct {
+	Paths   []string               `json:"paths"`
+	Folders []scanCheckpointFolder `json:"folders"`
+}
+
+// scanCheckpointer saves the scanned folders while scanning so an interrupted scan can resume
+type scanCheckpointer struct {
+	fileName string
+	paths    []string
+	previous map[string]scanCheckpointFolder
+
+	mu       sync.Mutex
+	folders  []scanCheckpointFolder
+	lastSave time.Time
+}
+
+// newScanCheckpointer loads the scan checkpoint file of a previous run of the same paths if there is one
+func newScanCheckpointer(fileName string, paths []string) (*scanCheckpointer, error) {
+	sc := &scanCheckpointer{
+		fileName: fileName,
+		paths:    paths,
+		previous: map[string]scanCheckpointFolder{},
+		lastSave: time.Now(),
+	}
+
+	contents, readErr := os.ReadFile(fileName)
+	if errors.Is(readErr, fs.ErrNotExist) {
+		return sc, nil
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("error reading scan checkpoint: %s", readErr)
+	}
+
+	previous := scanCheckpointData{}
+	if jsonErr := json.Unmarshal(contents, &previous); jsonErr != nil {
+		return nil, fmt.Errorf("error parsing scan checkpoint %s: %s", fileName, jsonErr)
+	}
+
+	// the checkpoint of other scan paths is started over
+	if fmt.Sprint(previous.Paths) != fmt.Sprint(paths) {
+		return sc, nil
+	}
+
+	for _, f := range previous.Folders {
+		sc.previous[f.Folder.Path] = f
+	}
+
+	return sc, nil
+}
+
+// resumed returns the number of folders saved by the previous run
+func (sc *scanCheckpointer) resumed() int {
+	return len(sc.previous)
+}
+
+// restore returns the folder at p if it was scanned by the previous run, it's saved again with this run's folders
+func (sc *scanCheckpointer) restore(p string) (*MusicFolder, bool) {
+	f, found := sc.previous[p]
+	if !found {
+		return nil, false
+	}
+
+	mf := f.Folder
+	mf.toc = f.TOC
+	sc.done(&mf)
+
+	return &mf, true
+}
+
+// done records a scanned folder and saves the checkpoint when it's due
+func (sc *scanCheckpointer) done(mf *MusicFolder) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.folders = append(sc.folders, scanCheckpointFolder{*mf, mf.toc})
+
+	if time.Since(sc.lastSave) < scanCheckpointInterval {
+		return
+	}
+	sc.lastSave = time.Now()
+
+	// saving is best effort, the scan carries on without it
+	if saveErr := sc.save(); saveErr != nil {
+		fmt.Fprintln(os.Stderr, saveErr)
+	}
+}
+
+// flush saves the folders scanned so far, so a failed scan keeps its progress
+func (sc *scanCheckpointer) flush() error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	return sc.save()
+}
+
+// save writes the checkpoint file, through a temp file so an interrupted save doesn't lose the previous one
+func (sc *scanCheckpointer) save() error {
+	contents, jsonErr := json.Marshal(scanCheckpointData{
+		Paths:   sc.paths,
+		Folders: sc.folders,
+	})
+	if jsonErr != nil {
+		return fmt.Errorf("error writing scan checkpoint: %s", jsonErr)
+	}
+
+	tmp := sc.fileName + ".tmp"
+	if writeErr := os.WriteFile(tmp, contents, 0644); writeErr != nil {
+		return fmt.Errorf("error writing scan checkpoint: %s", writeErr)
+	}
+
+	if renameErr := os.Rename(tmp, sc.fileName); renameErr != nil {
+		return fmt.Errorf("error writing scan checkpoint: %s", renameErr)
+	}
+
+	return nil
+}
+
+// remove deletes the checkpoint file once the scan is complete
+func (sc *scanCheckpointer) remove() error {
+	if removeErr := os.Remove(sc.fileName); removeErr != nil && !errors.Is(removeErr, fs.ErrNotExist) {
+		return fmt.Errorf("error removing scan checkpoint: %s", removeErr)
+	}
+	return nil
+}
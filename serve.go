@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// serveShutdownTimeout is how long in flight requests get to finish when the server is stopped
+const serveShutdownTimeout = 5 * time.Second
+
+// indexTemplate is the html page listing the scanned albums
+var indexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+	"bytes":    byteCountSI,
+	"duration": formatDuration,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>milkdud - {{.Stats.Path}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.25em 0.75em; text-align: left; border-bottom: 1px solid #ddd; }
+</style>
+</head>
+<body>
+<h1>{{.Stats.Path}}</h1>
+<p>
+{{.Stats.FolderCnt}} albums, {{.Stats.AccuripFolderCnt}} with accurip logs ({{printf "%.1f" .Stats.AccuripCoveragePercent}}%),
+{{.Stats.TotalFlacFiles}} flac files, {{.Stats.TotalFileSize}}, {{.Stats.TotalDuration}}
+</p>
+{{if .Stats.TorrentFileName}}<p><a href="/torrent">Download {{.Stats.TorrentFileName}}</a></p>{{end}}
+<table>
+<tr><th>Album</th><th>Accurip</th><th>TOCID</th><th>Files</th><th>Size</th><th>Duration</th></tr>
+{{range .Albums}}<tr>
+<td>{{.Path}}</td>
+<td>{{.AccuripStatus}}</td>
+<td>{{if .TocID}}<a href="{{.ToCID}}">{{.TocID}}</a>{{end}}</td>
+<td>{{.FileCnt}}</td>
+<td>{{bytes .TotalBytes}}</td>
+<td>{{duration .TotalDurationSeconds}}</td>
+</tr>{{end}}
+</table>
+</body>
+</html>
+`))
+
+// serve serves the scan results over http until interrupted
+func serve(addr string, stats Stats, albums []MusicFolder) error {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		indexTemplate.Execute(w, struct {
+			Stats  Stats
+			Albums []MusicFolder
+		}{stats, albums})
+	})
+
+	mux.HandleFunc("/api/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, newEnvelope(kindStats, stats))
+	})
+
+	mux.HandleFunc("/api/albums", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, newEnvelope(kindAlbums, albums))
+	})
+
+	mux.HandleFunc("/torrent", func(w http.ResponseWriter, r *http.Request) {
+		if len(stats.TorrentFileName) == 0 {
+			http.Error(w, "no torrent was created, run with -t", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-bittorrent")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(stats.TorrentFileName)))
+		http.ServeFile(w, r, stats.TorrentFileName)
+	})
+
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-interrupt
+		ctx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	fmt.Println("Serving scan results on", addr, "press Ctrl-C to exit")
+
+	if serveErr := srv.ListenAndServe(); !errors.Is(serveErr, http.ErrServerClosed) {
+		return fmt.Errorf("error serving scan results: %s", serveErr)
+	}
+
+	fmt.Println("Stopped serving", addr)
+
+	return nil
+}
+
+// writeJSON writes v as an indented json response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	b, jsonErr := json.MarshalIndent(v, "", "  ")
+	if jsonErr != nil {
+		http.Error(w, jsonErr.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,189 @@
+package sftpfs
+
+import (
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// defaultPort is the SSH port used when the URL doesn't have one
+const defaultPort = "22"
+
+// keyFiles are the private keys tried from ~/.ssh, in order
+var keyFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// FS interface for read only access to a folder on an SFTP server
+type FS interface {
+	fs.StatFS
+	fs.ReadDirFS
+}
+
+// sftpFS is the implementation of the FS interface
+type sftpFS struct {
+	client *sftp.Client
+	root   string
+}
+
+// Open opens a file for reading, the returned file supports ReadAt
+func (sf *sftpFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	f, openErr := sf.client.Open(path.Join(sf.root, name))
+	if openErr != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: openErr}
+	}
+
+	return f, nil
+}
+
+// Stat returns the file info of a file, following symlinks
+func (sf *sftpFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	info, statErr := sf.client.Stat(path.Join(sf.root, name))
+	if statErr != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: statErr}
+	}
+
+	return info, nil
+}
+
+// ReadDir reads a directory, the entries are sorted by name
+func (sf *sftpFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	infos, readErr := sf.client.ReadDir(path.Join(sf.root, name))
+	if readErr != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: readErr}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+// authMethods returns the password from the URL, the keys of a running ssh-agent and the unencrypted keys in ~/.ssh
+func authMethods(u *url.URL) []ssh.AuthMethod {
+	methods := []ssh.AuthMethod{}
+
+	if password, found := u.User.Password(); found {
+		methods = append(methods, ssh.Password(password))
+	}
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); len(sock) > 0 {
+		if conn, dialErr := net.Dial("unix", sock); dialErr == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	signers := []ssh.Signer{}
+	if home, homeErr := os.UserHomeDir(); homeErr == nil {
+		for _, keyFile := range keyFiles {
+			key, readErr := os.ReadFile(filepath.Join(home, ".ssh", keyFile))
+			if readErr != nil {
+				continue
+			}
+
+			signer, parseErr := ssh.ParsePrivateKey(key)
+			if parseErr != nil {
+				continue
+			}
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	return methods
+}
+
+// New connects to the server of an sftp://user@host:port/path URL and returns the folder at path.
+// The host key must be in ~/.ssh/known_hosts.
+func New(u *url.URL) (FS, error) {
+	if u.Scheme != "sftp" {
+		return nil, fmt.Errorf("invalid SFTP URL %s: scheme must be sftp", u.Redacted())
+	}
+
+	if len(u.Hostname()) == 0 {
+		return nil, fmt.Errorf("invalid SFTP URL %s: missing host", u.Redacted())
+	}
+
+	home, homeErr := os.UserHomeDir()
+	if homeErr != nil {
+		return nil, fmt.Errorf("error finding known_hosts %s", homeErr)
+	}
+
+	hostKeyCallback, knownHostsErr := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if knownHostsErr != nil {
+		return nil, fmt.Errorf("error reading known_hosts %s", knownHostsErr)
+	}
+
+	user := u.User.Username()
+	if len(user) == 0 {
+		user = os.Getenv("USER")
+	}
+
+	port := u.Port()
+	if len(port) == 0 {
+		port = defaultPort
+	}
+
+	conn, dialErr := ssh.Dial("tcp", net.JoinHostPort(u.Hostname(), port), &ssh.ClientConfig{
+		User:            user,
+		Auth:            authMethods(u),
+		HostKeyCallback: hostKeyCallback,
+	})
+	if dialErr != nil {
+		return nil, fmt.Errorf("error connecting to %s: %s", u.Host, dialErr)
+	}
+
+	client, clientErr := sftp.NewClient(conn)
+	if clientErr != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error starting SFTP session on %s: %s", u.Host, clientErr)
+	}
+
+	root := u.Path
+	if len(root) == 0 {
+		root = "."
+	}
+
+	info, statErr := client.Stat(root)
+	if statErr != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error reading %s on %s: %s", root, u.Host, statErr)
+	}
+	if !info.IsDir() {
+		conn.Close()
+		return nil, fmt.Errorf("%s on %s is not a directory", root, u.Host)
+	}
+
+	return &sftpFS{
+		client: client,
+		root:   root,
+	}, nil
+}
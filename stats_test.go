@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	stats := Stats{}
+
+	// three albums of known sizes, one without an accurip log
+	albums := []MusicFolder{
+		{Path: "/music/A", TotalBytes: 312_000_000, FileCnt: 10, FlacCnt: 10, HasAccurip: true, TotalDurationSeconds: 2400},
+		{Path: "/music/B", TotalBytes: 451_500_000, FileCnt: 14, FlacCnt: 14, HasAccurip: true, HasReplayGain: true, TotalDurationSeconds: 3000},
+		{Path: "/music/C", TotalBytes: 186_500_000, FileCnt: 6, FlacCnt: 6, TotalDurationSeconds: 1800},
+	}
+	for i := range albums {
+		stats.addFolder(&albums[i])
+	}
+	stats.summarize()
+
+	if stats.TotalFileSizeBytes != 950_000_000 || stats.TotalFileSize != "950.0 MB" {
+		t.Errorf("total = %d %s, want 950000000 950.0 MB", stats.TotalFileSizeBytes, stats.TotalFileSize)
+	}
+	if stats.AverageAlbumSizeBytes != 316_666_666 || stats.AverageAlbumSize != "316.7 MB" {
+		t.Errorf("average = %d %s, want 316666666 316.7 MB", stats.AverageAlbumSizeBytes, stats.AverageAlbumSize)
+	}
+	if stats.TotalFiles != 30 || stats.TotalFlacFiles != 30 {
+		t.Errorf("files = %d flac = %d, want 30 30", stats.TotalFiles, stats.TotalFlacFiles)
+	}
+	if stats.TotalDuration != "02:00:00" {
+		t.Errorf("duration = %s, want 02:00:00", stats.TotalDuration)
+	}
+
+	// removing an album and summarizing again recomputes from the new totals
+	stats.removeFolder(&albums[2])
+	stats.summarize()
+
+	if stats.AverageAlbumSizeBytes != 381_750_000 || stats.AverageAlbumSize != "381.8 MB" {
+		t.Errorf("average after remove = %d %s, want 381750000 381.8 MB", stats.AverageAlbumSizeBytes, stats.AverageAlbumSize)
+	}
+	if stats.AccuripCoveragePercent != 100 || stats.ReplayGainCoveragePercent != 50 {
+		t.Errorf("coverage = %v%% %v%%, want 100%% 50%%", stats.AccuripCoveragePercent, stats.ReplayGainCoveragePercent)
+	}
+}
+
+func TestSummarizeEmpty(t *testing.T) {
+	stats := Stats{}
+	stats.summarize()
+
+	if stats.AverageAlbumSizeBytes != 0 || stats.AverageAlbumSize != "0 B" || stats.AccuripCoveragePercent != 0 {
+		t.Errorf("empty library = %d %s %v%%, want 0 0 B 0%%", stats.AverageAlbumSizeBytes, stats.AverageAlbumSize, stats.AccuripCoveragePercent)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// AlbumStatus is the overall outcome of the quality checks of an album
+type AlbumStatus string
+
+const (
+	AlbumOK      AlbumStatus = "ok"      // no checks failed
+	AlbumWarning AlbumStatus = "warning" // worth a look but the rip is usable
+	AlbumProblem AlbumStatus = "problem" // the rip can't be trusted or is incomplete
+)
+
+// minAccuripConfidence is the lowest AccurateRip confidence that isn't reported as low
+const minAccuripConfidence = 2
+
+// rank orders the statuses from ok to problem
+func (as AlbumStatus) rank() int {
+	switch as {
+	case AlbumProblem:
+		return 2
+	case AlbumWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// assess sets the status of the album from the checks that ran and lists the issues found
+func (mf *MusicFolder) assess() {
+	mf.Status = AlbumOK
+	mf.Issues = nil
+
+	problem := func(format string, a ...interface{}) {
+		mf.Status = AlbumProblem
+		mf.Issues = append(mf.Issues, fmt.Sprintf(format, a...))
+	}
+	warning := func(format string, a ...interface{}) {
+		if mf.Status != AlbumProblem {
+			mf.Status = AlbumWarning
+		}
+		mf.Issues = append(mf.Issues, fmt.Sprintf(format, a...))
+	}
+
+	if *flagIgnoreRipLogs {
+		// the rip logs aren't checked
+	} else if !mf.HasAccurip {
+		problem("no accurip log")
+	} else {
+		if mf.AccuripStatus != AccuripConfirmed {
+			warning("accurip not confirmed: %s", mf.AccuripStatus)
+		}
+		if mf.AccuripConfidence > 0 && mf.AccuripConfidence < minAccuripConfidence {
+			warning("low accurip confidence: %d", mf.AccuripConfidence)
+		}
+		if !mf.LogSigned {
+			warning("unsigned log")
+		}
+	}
+
+	if mf.CTDBChecked && !mf.CTDBFound {
+		warning("not in the CueTools database")
+	}
+
+	if mf.LogSigned && !mf.LogChecksumValid {
+		problem("log checksum mismatch")
+	}
+
+	for _, p := range mf.InvalidFlacFiles {
+		problem("invalid flac file: %s", p)
+	}
+	for _, p := range mf.InvalidM4aFiles {
+		problem("invalid m4a file: %s", p)
+	}
+
+	// the expected track count is only known in beets mode
+	if mf.ExpectedTrackCnt > 0 && mf.trackCnt() != mf.ExpectedTrackCnt {
+		problem("incomplete: %d of %d tracks", mf.trackCnt(), mf.ExpectedTrackCnt)
+	}
+
+	for _, p := range mf.MissingCueFiles {
+		problem("missing cue file: %s", p)
+	}
+
+	for _, p := range mf.UntrackedFiles {
+		warning("missing from beets: %s", p)
+	}
+
+	for _, w := range mf.LintWarnings {
+		warning("lint: %s", w)
+	}
+}
+
+// sortedByStatus returns the albums with problems first, then warnings, keeping the scan order within a status
+func sortedByStatus(albums []MusicFolder) []MusicFolder {
+	sorted := make([]MusicFolder, len(albums))
+	copy(sorted, albums)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Status.rank() > sorted[j].Status.rank()
+	})
+
+	return sorted
+}
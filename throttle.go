@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"golang.org/x/time/rate"
+)
+
+// the limiters of a scan, set from -scan-rate and -max-iops, nil means unlimited
+var (
+	scanBytesLimiter *rate.Limiter
+	scanOpsLimiter   *rate.Limiter
+)
+
+// newScanLimiter creates a limiter allowing n bytes or operations per second, nil means unlimited
+func newScanLimiter(n int64) *rate.Limiter {
+	if n <= 0 {
+		return nil
+	}
+
+	return rate.NewLimiter(rate.Limit(n), int(n))
+}
+
+// waitN waits until the limiter allows n more bytes or operations, waiting at most one burst at a time
+func waitN(limiter *rate.Limiter, n int) error {
+	if limiter == nil {
+		return nil
+	}
+
+	for n > 0 {
+		chunk := n
+		if burst := limiter.Burst(); chunk > burst {
+			chunk = burst
+		}
+
+		if err := limiter.WaitN(context.Background(), chunk); err != nil {
+			return err
+		}
+
+		n = n - chunk
+	}
+
+	return nil
+}
+
+// throttleScan wraps fsys so opening, listing and reading files are limited by -max-iops and -scan-rate
+func throttleScan(fsys fs.FS) fs.FS {
+	if scanBytesLimiter == nil && scanOpsLimiter == nil {
+		return fsys
+	}
+	return &throttledFS{fsys}
+}
+
+// throttledFS is a file system whose operations and reads wait for the scan limiters
+type throttledFS struct {
+	fsys fs.FS
+}
+
+// Open opens a file once an operation is allowed
+func (tf *throttledFS) Open(name string) (fs.File, error) {
+	if waitErr := waitN(scanOpsLimiter, 1); waitErr != nil {
+		return nil, waitErr
+	}
+
+	f, openErr := tf.fsys.Open(name)
+	if openErr != nil {
+		return nil, openErr
+	}
+
+	return &throttledFile{f}, nil
+}
+
+// Stat returns the file info of a file once an operation is allowed
+func (tf *throttledFS) Stat(name string) (fs.FileInfo, error) {
+	if waitErr := waitN(scanOpsLimiter, 1); waitErr != nil {
+		return nil, waitErr
+	}
+	return fs.Stat(tf.fsys, name)
+}
+
+// ReadDir lists a directory once an operation is allowed
+func (tf *throttledFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if waitErr := waitN(scanOpsLimiter, 1); waitErr != nil {
+		return nil, waitErr
+	}
+	return fs.ReadDir(tf.fsys, name)
+}
+
+// throttledFile is a file whose reads wait for the bytes limiter
+type throttledFile struct {
+	fs.File
+}
+
+// Read reads from the file, waiting for the bytes read afterwards
+func (f *throttledFile) Read(p []byte) (int, error) {
+	n, readErr := f.File.Read(p)
+	if waitErr := waitN(scanBytesLimiter, n); waitErr != nil && readErr == nil {
+		readErr = waitErr
+	}
+	return n, readErr
+}
+
+// ReadAt reads from the file at off if the file supports it, waiting for the bytes read afterwards
+func (f *throttledFile) ReadAt(p []byte, off int64) (int, error) {
+	ra, ok := f.File.(io.ReaderAt)
+	if !ok {
+		return 0, fmt.Errorf("file does not support random access")
+	}
+
+	n, readErr := ra.ReadAt(p, off)
+	if waitErr := waitN(scanBytesLimiter, n); waitErr != nil && readErr == nil {
+		readErr = waitErr
+	}
+	return n, readErr
+}
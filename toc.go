@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"concretelabs/milkdud/ctdb"
+)
+
+// logTOCRegexp matches a track row of the TOC table of EAC and CUETools logs: track | start | length | start sector | end sector
+var logTOCRegexp = regexp.MustCompile(`(?m)^\s*(\d+)\s*\|\s*[\d:.]+\s*\|\s*[\d:.]+\s*\|\s*(\d+)\s*\|\s*(\d+)\s*$`)
+
+// parseLogTOC returns the TOC of the first TOC table in a log as the colon seperated start sectors of the tracks
+// followed by the lead-out sector, an empty string if the log has no TOC table
+func parseLogTOC(log string) string {
+	sectors := []string{}
+	leadOut := 0
+
+	for _, match := range logTOCRegexp.FindAllStringSubmatch(log, -1) {
+		// a log of several rips has a table per rip
+		if match[1] == "1" && len(sectors) > 0 {
+			break
+		}
+
+		end, _ := strconv.Atoi(match[3])
+		sectors = append(sectors, match[2])
+		leadOut = end + 1
+	}
+
+	if len(sectors) == 0 {
+		return ""
+	}
+
+	return strings.Join(append(sectors, strconv.Itoa(leadOut)), ":")
+}
+
+// verifyCTDB looks up an accurip album in the CueTools database, albums whose log has no TOC table aren't checked
+func verifyCTDB(c ctdb.CTDB, mf *MusicFolder) error {
+	if len(mf.TocID) == 0 || len(mf.toc) == 0 {
+		return nil
+	}
+
+	result, lookupErr := c.Lookup(mf.TocID, mf.toc)
+	if lookupErr != nil {
+		return fmt.Errorf("error looking up %s in the CueTools database: %w", mf.Path, lookupErr)
+	}
+
+	mf.CTDBChecked = true
+	mf.CTDBFound = result.Found
+	mf.CTDBConfidence = result.Confidence
+
+	return nil
+}
+
+// hyperlink makes url clickable in terminals that support OSC 8 links, others print it as is
+func hyperlink(url string) string {
+	return fmt.Sprintf("\x1b]8;;%s\x1b\\%s\x1b]8;;\x1b\\", url, url)
+}
+
+// printTocReport prints the TOCID and CueTools lookup URL of every accurip album. When stdout is
+// not a terminal only the URLs are printed, one per line, so they can be piped to other tools.
+func printTocReport(albums []MusicFolder) {
+	if !isTerminal(os.Stdout) {
+		for _, album := range albums {
+			if len(album.TocID) > 0 {
+				fmt.Println(album.ToCID())
+			}
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Album\tTOCID\tCTDB\tCueTools URL")
+	for _, album := range albums {
+		if len(album.TocID) == 0 {
+			continue
+		}
+
+		name := filepath.Base(album.Path)
+		if len(album.Artist) > 0 || len(album.Title) > 0 {
+			name = fmt.Sprintf("%s - %s", album.Artist, album.Title)
+		}
+
+		ctdbStatus := "-"
+		if album.CTDBChecked && album.CTDBFound {
+			ctdbStatus = fmt.Sprintf("confidence %d", album.CTDBConfidence)
+		} else if album.CTDBChecked {
+			ctdbStatus = "not found"
+		}
+
+		// the URL is the last column so the escape codes of the link don't throw off the alignment
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, album.TocID, ctdbStatus, hyperlink(album.ToCID()))
+	}
+	w.Flush()
+}
+
+// isTerminal returns true if f is a terminal
+func isTerminal(f *os.File) bool {
+	info, statErr := f.Stat()
+	if statErr != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
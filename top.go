@@ -0,0 +1,40 @@
+package main
+
+import (
+	"sort"
+)
+
+// AlbumSize is the path and size of an album
+type AlbumSize struct {
+	Path       string `json:"path"`
+	TotalBytes int64  `json:"total_bytes"`
+}
+
+// albumExtremes returns the n largest albums, largest first, and the n smallest albums, smallest first
+func albumExtremes(albums []MusicFolder, n int) ([]AlbumSize, []AlbumSize) {
+	sizes := make([]AlbumSize, 0, len(albums))
+	for _, album := range albums {
+		sizes = append(sizes, AlbumSize{album.Path, album.TotalBytes})
+	}
+
+	// equal sizes are ordered by path so the output is stable
+	sort.Slice(sizes, func(i, j int) bool {
+		if sizes[i].TotalBytes != sizes[j].TotalBytes {
+			return sizes[i].TotalBytes > sizes[j].TotalBytes
+		}
+		return sizes[i].Path < sizes[j].Path
+	})
+
+	if n > len(sizes) {
+		n = len(sizes)
+	}
+
+	largest := append([]AlbumSize{}, sizes[:n]...)
+
+	smallest := []AlbumSize{}
+	for i := len(sizes) - 1; i >= len(sizes)-n; i-- {
+		smallest = append(smallest, sizes[i])
+	}
+
+	return largest, smallest
+}
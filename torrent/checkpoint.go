@@ -0,0 +1,172 @@
+package torrent
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// checkpointInterval is how often the hashed pieces are saved to the checkpoint file
+const checkpointInterval = 10 * time.Second
+
+// checkpointData is what's saved in a checkpoint file, the pieces hashed from the start of the torrent
+type checkpointData struct {
+	PieceLength int64  `json:"piece_length"`
+	Files       string `json:"files"` // digest of the file list so a checkpoint of other files isn't used
+	PieceCnt    int64  `json:"piece_count"`
+	Pieces      []byte `json:"pieces"`
+}
+
+// checkpointer saves the hashed pieces while hashing so an interrupted run can resume
+type checkpointer struct {
+	fileName    string
+	pieceLength int64
+	files       string
+	previous    *checkpointData
+
+	mu        sync.Mutex
+	completed map[int64]bool
+	next      int64 // pieces before next are all hashed
+	lastSave  time.Time
+	saveErr   error
+}
+
+// filesDigest returns a digest of the piece length and the paths and lengths of the files of a torrent
+func filesDigest(info *metainfo.Info) string {
+	h := sha1.New()
+	fmt.Fprintln(h, info.Name, info.PieceLength)
+	for _, fi := range info.UpvertedFiles() {
+		fmt.Fprintln(h, strings.Join(fi.Path, "/"), fi.Length)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newCheckpointer loads the checkpoint file of a previous run of the same torrent if there is one
+func newCheckpointer(fileName string, info *metainfo.Info) (*checkpointer, error) {
+	cp := &checkpointer{
+		fileName:    fileName,
+		pieceLength: info.PieceLength,
+		files:       filesDigest(info),
+		completed:   map[int64]bool{},
+		lastSave:    time.Now(),
+	}
+
+	contents, readErr := os.ReadFile(fileName)
+	if errors.Is(readErr, fs.ErrNotExist) {
+		return cp, nil
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("error reading checkpoint: %s", readErr)
+	}
+
+	previous := checkpointData{}
+	if jsonErr := json.Unmarshal(contents, &previous); jsonErr != nil {
+		return nil, fmt.Errorf("error parsing checkpoint %s: %s", fileName, jsonErr)
+	}
+
+	// the checkpoint of a different set of files is started over
+	if previous.Files == cp.files && previous.PieceLength == info.PieceLength {
+		cp.previous = &previous
+	}
+
+	return cp, nil
+}
+
+// resume copies the pieces of the previous run into pieces and returns the first piece left to hash. The first
+// and last saved pieces are hashed again to check the files didn't change since, otherwise hashing starts over.
+func (cp *checkpointer) resume(pieces []byte, pieceCnt int64, pieceHash func() hash.Hash, hashPiece func(i int64, h hash.Hash) ([]byte, error)) int64 {
+	prev := cp.previous
+	if prev == nil || prev.PieceCnt <= 0 || prev.PieceCnt > pieceCnt {
+		return 0
+	}
+
+	size := int64(pieceHash().Size())
+	if int64(len(prev.Pieces)) != prev.PieceCnt*size {
+		return 0
+	}
+
+	for _, i := range []int64{0, prev.PieceCnt - 1} {
+		digest, hashErr := hashPiece(i, pieceHash())
+		if hashErr != nil || !bytes.Equal(digest, prev.Pieces[i*size:(i+1)*size]) {
+			return 0
+		}
+	}
+
+	copy(pieces, prev.Pieces)
+	cp.next = prev.PieceCnt
+
+	return prev.PieceCnt
+}
+
+// done records a hashed piece and saves the checkpoint when it's due
+func (cp *checkpointer) done(i int64, pieces []byte, size int) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.completed[i] = true
+	for cp.completed[cp.next] {
+		delete(cp.completed, cp.next)
+		cp.next = cp.next + 1
+	}
+
+	if time.Since(cp.lastSave) < checkpointInterval {
+		return
+	}
+	cp.lastSave = time.Now()
+
+	// saving is best effort, hashing carries on without it
+	if saveErr := cp.save(pieces[:cp.next*int64(size)]); saveErr != nil && cp.saveErr == nil {
+		cp.saveErr = saveErr
+	}
+}
+
+// flush saves the pieces hashed so far, so a failed run keeps its progress
+func (cp *checkpointer) flush(pieces []byte, size int) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	return cp.save(pieces[:cp.next*int64(size)])
+}
+
+// save writes the checkpoint file, through a temp file so an interrupted save doesn't lose the previous one
+func (cp *checkpointer) save(pieces []byte) error {
+	contents, jsonErr := json.Marshal(checkpointData{
+		PieceLength: cp.pieceLength,
+		Files:       cp.files,
+		PieceCnt:    cp.next,
+		Pieces:      pieces,
+	})
+	if jsonErr != nil {
+		return jsonErr
+	}
+
+	tmp := cp.fileName + ".tmp"
+	if writeErr := os.WriteFile(tmp, contents, 0644); writeErr != nil {
+		return fmt.Errorf("error writing checkpoint: %s", writeErr)
+	}
+
+	if renameErr := os.Rename(tmp, cp.fileName); renameErr != nil {
+		return fmt.Errorf("error writing checkpoint: %s", renameErr)
+	}
+
+	return nil
+}
+
+// remove deletes the checkpoint file once the torrent is complete
+func (cp *checkpointer) remove() error {
+	if removeErr := os.Remove(cp.fileName); removeErr != nil && !errors.Is(removeErr, fs.ErrNotExist) {
+		return fmt.Errorf("error removing checkpoint: %s", removeErr)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package torrent
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// Dump is the decoded metainfo of a torrent file
+type Dump struct {
+	InfoHash     string     `json:"info_hash"`
+	Name         string     `json:"name"`
+	Announce     string     `json:"announce,omitempty"`
+	AnnounceList [][]string `json:"announce_list"`
+	WebSeeds     []string   `json:"web_seeds,omitempty"`
+	Comment      string     `json:"comment,omitempty"`
+	CreatedBy    string     `json:"created_by,omitempty"`
+	CreationDate string     `json:"creation_date,omitempty"`
+	PieceLength  int64      `json:"piece_length"`
+	PieceCnt     int        `json:"piece_count"`
+	Private      bool       `json:"private"`
+	Source       string     `json:"source,omitempty"`
+	TotalLength  int64      `json:"total_length"`
+	Files        []DumpFile `json:"files"`
+}
+
+// DumpFile is a file listed in a torrent
+type DumpFile struct {
+	Path   string `json:"path"`
+	Length int64  `json:"length"`
+}
+
+// Load decodes a torrent file for inspection
+func Load(fileName string) (*Dump, error) {
+	mi, loadErr := metainfo.LoadFromFile(fileName)
+	if loadErr != nil {
+		return nil, fmt.Errorf("error loading torrent file: %s", loadErr)
+	}
+
+	var info metainfo.Info
+	if bencodeErr := bencode.Unmarshal(mi.InfoBytes, &info); bencodeErr != nil {
+		return nil, fmt.Errorf("error decoding torrent info: %s", bencodeErr)
+	}
+
+	d := Dump{
+		InfoHash:     mi.HashInfoBytes().HexString(),
+		Name:         info.Name,
+		Announce:     mi.Announce,
+		AnnounceList: mi.AnnounceList,
+		WebSeeds:     mi.UrlList,
+		Comment:      mi.Comment,
+		CreatedBy:    mi.CreatedBy,
+		PieceLength:  info.PieceLength,
+		PieceCnt:     info.NumPieces(),
+		Private:      info.Private != nil && *info.Private,
+		Source:       info.Source,
+		TotalLength:  info.TotalLength(),
+		Files:        []DumpFile{},
+	}
+
+	if mi.CreationDate > 0 {
+		d.CreationDate = time.Unix(mi.CreationDate, 0).UTC().Format(time.RFC3339)
+	}
+
+	for _, fi := range info.UpvertedFiles() {
+		d.Files = append(d.Files, DumpFile{
+			Path:   strings.Join(append([]string{info.Name}, fi.Path...), "/"),
+			Length: fi.Length,
+		})
+	}
+
+	return &d, nil
+}
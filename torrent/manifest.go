@@ -0,0 +1,99 @@
+package torrent
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+)
+
+// manifestHashes are the digests a manifest can be written with, each matches the coreutils <name>sum format
+var manifestHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// ManifestHash returns the hash a manifest is written with by name, ex: sha256
+func ManifestHash(name string) (func() hash.Hash, error) {
+	newHash, found := manifestHashes[strings.ToLower(name)]
+	if !found {
+		names := []string{}
+		for n := range manifestHashes {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown manifest hash %s, must be one of %s", name, strings.Join(names, ", "))
+	}
+
+	return newHash, nil
+}
+
+// manifestWriter writes a sha256sum (or the sum tool of its hash) compatible manifest from the pieces of the torrent read in order
+type manifestWriter struct {
+	w      io.Writer
+	spans  []fileSpan
+	span   int
+	offset int64
+	h      hash.Hash
+	err    error
+}
+
+func newManifestWriter(w io.Writer, spans []fileSpan, newHash func() hash.Hash) *manifestWriter {
+	return &manifestWriter{
+		w:     w,
+		spans: spans,
+		h:     newHash(),
+	}
+}
+
+// write hashes the next piece of the concatenated byte stream, pieces must be written in order
+func (mw *manifestWriter) write(data []byte) {
+	for len(data) > 0 && mw.err == nil {
+		mw.flushComplete()
+		if mw.span >= len(mw.spans) {
+			return
+		}
+
+		s := mw.spans[mw.span]
+		n := s.offset + s.length - mw.offset
+		if n > int64(len(data)) {
+			n = int64(len(data))
+		}
+
+		mw.h.Write(data[:n])
+		mw.offset = mw.offset + n
+		data = data[n:]
+	}
+	mw.flushComplete()
+}
+
+// flushComplete writes the manifest line of every file that has been completely hashed
+func (mw *manifestWriter) flushComplete() {
+	for mw.err == nil && mw.span < len(mw.spans) {
+		s := mw.spans[mw.span]
+		if mw.offset < s.offset+s.length {
+			return
+		}
+
+		_, mw.err = fmt.Fprintf(mw.w, "%s  %s\n", hex.EncodeToString(mw.h.Sum(nil)), s.relPath)
+		mw.h.Reset()
+		mw.span = mw.span + 1
+	}
+}
+
+// close writes any remaining (empty) files and returns the first write error
+func (mw *manifestWriter) close() error {
+	mw.flushComplete()
+	if mw.err != nil {
+		return fmt.Errorf("error writing manifest: %s", mw.err)
+	}
+	return nil
+}
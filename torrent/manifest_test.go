@@ -0,0 +1,132 @@
+package torrent
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManifestWriter(t *testing.T) {
+	dir := t.TempDir()
+
+	// an empty file and files spanning several pieces, one of them in a sub folder
+	files := []struct {
+		relPath string
+		data    []byte
+	}{
+		{"Album/01 Track.flac", bytes.Repeat([]byte("a"), 1000)},
+		{"Album/02 Track.flac", []byte{}},
+		{"Album/Disc 2/01 Track.flac", bytes.Repeat([]byte("b"), 2500)},
+		{"Album/rip.log", []byte("Exact Audio Copy\n")},
+	}
+
+	spans := []fileSpan{}
+	stream := []byte{}
+	for _, f := range files {
+		p := filepath.Join(dir, filepath.FromSlash(f.relPath))
+		if mkdirErr := os.MkdirAll(filepath.Dir(p), 0755); mkdirErr != nil {
+			t.Fatal(mkdirErr)
+		}
+		if writeErr := os.WriteFile(p, f.data, 0644); writeErr != nil {
+			t.Fatal(writeErr)
+		}
+
+		spans = append(spans, fileSpan{p, f.relPath, int64(len(stream)), int64(len(f.data))})
+		stream = append(stream, f.data...)
+	}
+
+	// pieces don't line up with the file boundaries
+	manifest := bytes.Buffer{}
+	mw := newManifestWriter(&manifest, spans, sha256.New)
+	for pieceLength := 768; len(stream) > 0; {
+		if pieceLength > len(stream) {
+			pieceLength = len(stream)
+		}
+		mw.write(stream[:pieceLength])
+		stream = stream[pieceLength:]
+	}
+	if closeErr := mw.close(); closeErr != nil {
+		t.Fatalf("close() error = %v", closeErr)
+	}
+
+	// every line is "<hex digest>  <path>" like sha256sum writes, with the files in torrent order
+	lines := []string{}
+	scanner := bufio.NewScanner(&manifest)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if len(lines) != len(files) {
+		t.Fatalf("manifest has %d lines, want %d:\n%s", len(lines), len(files), strings.Join(lines, "\n"))
+	}
+
+	for i, line := range lines {
+		digest, relPath, found := strings.Cut(line, "  ")
+		if !found {
+			t.Errorf("line %d = %q, want a digest and path seperated by two spaces", i, line)
+			continue
+		}
+
+		sum := sha256.Sum256(files[i].data)
+		if relPath != files[i].relPath || digest != hex.EncodeToString(sum[:]) {
+			t.Errorf("line %d = %q, want %s  %s", i, line, hex.EncodeToString(sum[:]), files[i].relPath)
+		}
+	}
+
+	// check the manifest with the tool it's written for when it's installed
+	sha256sum, lookErr := exec.LookPath("sha256sum")
+	if lookErr != nil {
+		t.Log("sha256sum not found, skipping sha256sum -c")
+		return
+	}
+
+	manifestFile := filepath.Join(dir, "manifest.sha256")
+	if writeErr := os.WriteFile(manifestFile, []byte(strings.Join(lines, "\n")+"\n"), 0644); writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	cmd := exec.Command(sha256sum, "-c", manifestFile)
+	cmd.Dir = dir
+	if out, checkErr := cmd.CombinedOutput(); checkErr != nil {
+		t.Errorf("sha256sum -c error = %v\n%s", checkErr, out)
+	}
+}
+
+func TestManifestHash(t *testing.T) {
+	// digests of "abc" from the test vectors of each algorithm
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"md5", "900150983cd24fb0d6963f7d28e17f72"},
+		{"sha1", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{"sha256", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"SHA256", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"sha512", "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newHash, hashErr := ManifestHash(tt.name)
+			if hashErr != nil {
+				t.Fatalf("ManifestHash(%s) error = %v", tt.name, hashErr)
+			}
+
+			h := newHash()
+			h.Write([]byte("abc"))
+			if got := hex.EncodeToString(h.Sum(nil)); got != tt.want {
+				t.Errorf("ManifestHash(%s) digest = %s, want %s", tt.name, got, tt.want)
+			}
+		})
+	}
+
+	_, hashErr := ManifestHash("crc32")
+	if hashErr == nil || !strings.Contains(hashErr.Error(), "md5, sha1, sha256, sha512") {
+		t.Errorf("ManifestHash(crc32) error = %v, want the supported hashes listed", hashErr)
+	}
+}
@@ -0,0 +1,312 @@
+package torrent
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"golang.org/x/time/rate"
+)
+
+// fileSpan is the location of a file in the concatenated byte stream of the torrent
+type fileSpan struct {
+	path    string
+	relPath string
+	offset  int64
+	length  int64
+}
+
+// pieceReader reads pieces from the concatenated byte stream of the torrent files
+type pieceReader struct {
+	spans []fileSpan
+	open  func(string) (fs.File, error)
+
+	// the most recently opened file is kept open as pieces are mostly read in order
+	f     fs.File
+	ra    io.ReaderAt
+	fPath string
+}
+
+// buildSpans builds the offset map of each file in the concatenated byte stream, source maps a torrent path to the file it is read from
+func buildSpans(root string, source func(string) string, info *metainfo.Info) ([]fileSpan, int64) {
+	spans := []fileSpan{}
+	offset := int64(0)
+
+	for _, fi := range info.UpvertedFiles() {
+		spans = append(spans, fileSpan{
+			path:    source(filepath.Join(root, strings.Join(fi.Path, string(filepath.Separator)))),
+			relPath: strings.Join(fi.Path, "/"),
+			offset:  offset,
+			length:  fi.Length,
+		})
+		offset = offset + fi.Length
+	}
+
+	return spans, offset
+}
+
+// readAt fills b with the bytes of the stream starting at offset, crossing file boundaries as needed
+func (pr *pieceReader) readAt(b []byte, offset int64) error {
+	for len(b) > 0 {
+		span, found := pr.spanAt(offset)
+		if !found {
+			return fmt.Errorf("offset %d is past the end of the torrent", offset)
+		}
+
+		if pr.fPath != span.path {
+			pr.close()
+
+			f, openErr := pr.open(span.path)
+			if openErr != nil {
+				return fmt.Errorf("error opening %s: %s", span.path, openErr)
+			}
+
+			// pieces can start anywhere in a file
+			ra, ok := f.(io.ReaderAt)
+			if !ok {
+				f.Close()
+				return fmt.Errorf("error reading %s: random access isn't supported", span.path)
+			}
+			pr.f, pr.ra, pr.fPath = f, ra, span.path
+		}
+
+		n := span.offset + span.length - offset
+		if n > int64(len(b)) {
+			n = int64(len(b))
+		}
+
+		if _, readErr := pr.ra.ReadAt(b[:n], offset-span.offset); readErr != nil {
+			if readErr == io.EOF {
+				return fmt.Errorf("error reading %s: file is shorter than expected", span.path)
+			}
+			return fmt.Errorf("error reading %s: %s", span.path, readErr)
+		}
+
+		b = b[n:]
+		offset = offset + n
+	}
+
+	return nil
+}
+
+// spanAt finds the file containing offset, skipping empty files
+func (pr *pieceReader) spanAt(offset int64) (fileSpan, bool) {
+	lo, hi := 0, len(pr.spans)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if pr.spans[mid].offset+pr.spans[mid].length <= offset {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	if lo == len(pr.spans) {
+		return fileSpan{}, false
+	}
+
+	return pr.spans[lo], true
+}
+
+func (pr *pieceReader) close() {
+	if pr.f != nil {
+		pr.f.Close()
+		pr.f, pr.ra, pr.fPath = nil, nil, ""
+	}
+}
+
+// pieceData is the contents of a piece passed on for the manifest
+type pieceData struct {
+	index int64
+	data  []byte
+}
+
+// hashPieces hashes each piece of the torrent with pieceHash on a pool of workers and returns the concatenated
+// digests in order. If manifest isn't nil a manifest of the files hashed with manifestHash is written to it from
+// the same reads. If cp isn't nil the hashed pieces are saved to its checkpoint file and the pieces of a previous
+// run are reused, unless a manifest is written as it needs every file read.
+func hashPieces(root string, source func(string) string, open func(string) (fs.File, error), info *metainfo.Info, workers int, limiter *rate.Limiter, pieceHash func() hash.Hash, manifest io.Writer, manifestHash func() hash.Hash, cp *checkpointer, logOutput bool) ([]byte, error) {
+	spans, totalLength := buildSpans(root, source, info)
+
+	digestSize := pieceHash().Size()
+	pieceCnt := (totalLength + info.PieceLength - 1) / info.PieceLength
+	pieces := make([]byte, pieceCnt*int64(digestSize))
+
+	// pieceBounds returns the offset and length of a piece, the last piece can be shorter
+	pieceBounds := func(i int64) (int64, int64) {
+		offset := i * info.PieceLength
+		length := info.PieceLength
+		if offset+length > totalLength {
+			length = totalLength - offset
+		}
+		return offset, length
+	}
+
+	start := int64(0)
+	if cp != nil && manifest == nil {
+		pr := pieceReader{spans: spans, open: open}
+		buf := make([]byte, info.PieceLength)
+
+		start = cp.resume(pieces, pieceCnt, pieceHash, func(i int64, h hash.Hash) ([]byte, error) {
+			offset, length := pieceBounds(i)
+			if err := pr.readAt(buf[:length], offset); err != nil {
+				return nil, err
+			}
+			h.Write(buf[:length])
+			return h.Sum(nil), nil
+		})
+		pr.close()
+
+		if start > 0 && logOutput {
+			fmt.Println("Resuming from piece", start, "of", pieceCnt)
+		}
+	}
+
+	c := make(chan int64)
+	errs := make(chan error, workers)
+	done := make(chan struct{})
+
+	// the manifest needs the pieces in order, inflight bounds how many pieces can wait to be put back in order
+	var manifestC chan pieceData
+	var inflight chan struct{}
+	manifestDone := make(chan error, 1)
+	if manifest != nil {
+		manifestC = make(chan pieceData)
+		inflight = make(chan struct{}, workers*2)
+
+		go func() {
+			mw := newManifestWriter(manifest, spans, manifestHash)
+			pending := map[int64][]byte{}
+			next := int64(0)
+
+			for pd := range manifestC {
+				pending[pd.index] = pd.data
+				for data, ok := pending[next]; ok; data, ok = pending[next] {
+					mw.write(data)
+					delete(pending, next)
+					next = next + 1
+					<-inflight
+				}
+			}
+
+			manifestDone <- mw.close()
+		}()
+	} else {
+		manifestDone <- nil
+	}
+
+	worker := func(wg *sync.WaitGroup) {
+		defer wg.Done()
+
+		pr := pieceReader{spans: spans, open: open}
+		defer pr.close()
+
+		buf := make([]byte, info.PieceLength)
+		h := pieceHash()
+
+		for i := range c {
+			// the manifest takes ownership of the buffer
+			if manifest != nil {
+				buf = make([]byte, info.PieceLength)
+			}
+
+			offset, length := pieceBounds(i)
+
+			if err := throttle(limiter, int(length)); err != nil {
+				errs <- err
+				return
+			}
+
+			if err := pr.readAt(buf[:length], offset); err != nil {
+				errs <- err
+				return
+			}
+
+			// each piece has its own slot so no locking is needed
+			h.Reset()
+			h.Write(buf[:length])
+			h.Sum(pieces[i*int64(digestSize) : i*int64(digestSize)])
+
+			if cp != nil {
+				cp.done(i, pieces, digestSize)
+			}
+
+			if manifest != nil {
+				manifestC <- pieceData{i, buf[:length]}
+			}
+
+			if logOutput {
+				fmt.Printf(".")
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go worker(&wg)
+	}
+
+	// allocate
+	go func() {
+		defer close(c)
+		for i := start; i < pieceCnt; i++ {
+			if inflight != nil {
+				select {
+				case inflight <- struct{}{}:
+				case <-done:
+					return
+				}
+			}
+
+			select {
+			case c <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(errs)
+		if manifestC != nil {
+			close(manifestC)
+		}
+	}()
+
+	// stop allocating work on the first error
+	var firstErr error
+	for err := range errs {
+		if firstErr == nil {
+			firstErr = err
+			close(done)
+		}
+	}
+
+	if logOutput {
+		fmt.Printf("\n")
+	}
+
+	manifestErr := <-manifestDone
+
+	if firstErr != nil {
+		if cp != nil {
+			if flushErr := cp.flush(pieces, digestSize); flushErr != nil {
+				return nil, fmt.Errorf("%s, %s", firstErr, flushErr)
+			}
+		}
+		return nil, firstErr
+	}
+
+	if manifestErr != nil {
+		return nil, manifestErr
+	}
+
+	return pieces, nil
+}
@@ -0,0 +1,58 @@
+package torrent
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// benchmarkLibrary writes files of an album to dir, with sizes that don't line up with the pieces
+func benchmarkLibrary(b *testing.B, dir string) *metainfo.Info {
+	info := &metainfo.Info{PieceLength: 256 * 1024}
+
+	for i := 1; i <= 12; i++ {
+		name := fmt.Sprintf("%02d Track.flac", i)
+		data := make([]byte, 3*1024*1024+i*4099)
+		if _, randErr := rand.Read(data); randErr != nil {
+			b.Fatal(randErr)
+		}
+		if writeErr := os.WriteFile(filepath.Join(dir, name), data, 0644); writeErr != nil {
+			b.Fatal(writeErr)
+		}
+
+		info.Files = append(info.Files, metainfo.FileInfo{Path: []string{name}, Length: int64(len(data))})
+	}
+
+	return info
+}
+
+func BenchmarkHashPieces(b *testing.B) {
+	dir := b.TempDir()
+	info := benchmarkLibrary(b, dir)
+
+	source := func(p string) string { return p }
+	open := func(p string) (fs.File, error) { return os.Open(p) }
+
+	workerCnts := []int{1, 2, 4}
+	if runtime.NumCPU() > 4 {
+		workerCnts = append(workerCnts, runtime.NumCPU())
+	}
+
+	for _, workers := range workerCnts {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.SetBytes(info.TotalLength())
+			for i := 0; i < b.N; i++ {
+				if _, hashErr := hashPieces(dir, source, open, info, workers, nil, sha1.New, nil, nil, nil, false); hashErr != nil {
+					b.Fatal(hashErr)
+				}
+			}
+		})
+	}
+}
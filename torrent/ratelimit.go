@@ -0,0 +1,38 @@
+package torrent
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// newReadLimiter creates a limiter allowing bytesPerSecond bytes to be read per second, nil means unlimited
+func newReadLimiter(bytesPerSecond int64) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), int(bytesPerSecond))
+}
+
+// throttle waits until the limiter allows n bytes to be read, waiting at most one burst at a time
+func throttle(limiter *rate.Limiter, n int) error {
+	if limiter == nil {
+		return nil
+	}
+
+	for n > 0 {
+		chunk := n
+		if burst := limiter.Burst(); chunk > burst {
+			chunk = burst
+		}
+
+		if err := limiter.WaitN(context.Background(), chunk); err != nil {
+			return err
+		}
+
+		n = n - chunk
+	}
+
+	return nil
+}
@@ -1,28 +1,48 @@
 package torrent
 
 import (
+	"bufio"
 	"crypto/sha1"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
-	"sync"
 	"time"
 
+	"concretelabs/milkdud/natsort"
+
 	"github.com/anacrolix/missinggo/v2/slices"
 	"github.com/anacrolix/torrent/bencode"
 	"github.com/anacrolix/torrent/metainfo"
-	"golang.org/x/sync/errgroup"
 )
 
+// torrentFsBase is the default name of the torrent root folder
 const torrentFsBase = "music"
 
+// DefaultCreatedBy is the tool string recorded in created torrents
+const DefaultCreatedBy = "github.com/concretelabs/milkdud"
+
 type TorrentFile interface {
-	AddFile(path string, size int64)
+	AddFile(path string, size int64) error
+	AddFileFrom(path, source string, size int64) error
+	SetReadRate(bytesPerSecond int64)
+	SetHashWorkers(workers int)
+	SetPrivate(private bool)
+	SetManifest(fileName string)
+	SetManifestHash(newHash func() hash.Hash)
+	SetCheckpoint(fileName string)
+	SetCreationDate(date int64)
+	SetCreatedBy(createdBy string)
+	SetRootFromPath(rootFromPath bool)
+	SetWebSeeds(urls []string)
+	SetFS(fsys fs.FS)
 	Create(outFile string) error
 	MagnetURL() string
 }
@@ -31,21 +51,52 @@ type torrentFile struct {
 	// t                  torrent
 	totalFileSizeBytes int64
 	root               string
+	name               string
 	paths              map[string]int64
+	sources            map[string]string
 	files              []metainfo.FileInfo
 	announce           []string
 	mi                 *metainfo.MetaInfo
 	logOutput          bool
+	readRate           int64
+	hashWorkers        int // 0 uses one worker per CPU
+	private            bool
+	manifest           string
+	manifestHash       func() hash.Hash // nil writes a sha256 manifest
+	checkpoint         string           // file the hashed pieces are saved to so hashing can resume
+	rootFromPath       bool
+	pieceLength        int64  // 0 chooses a piece length from the total size
+	infoSource         string // source tag of an existing torrent
+	fsys               fs.FS  // files are read from fsys relative to root instead of the local filesystem
 }
 
-// AddFile adds a file to the torrent
-func (tf *torrentFile) AddFile(path string, size int64) {
-	relativePath, err := filepath.Rel(tf.root, path)
-	if err != nil {
-		panic(err)
+// AddFile adds a file to the torrent, the torrent root is moved up to the common ancestor of the
+// root and the file when the file is outside of it
+func (tf *torrentFile) AddFile(path string, size int64) error {
+	path = filepath.Clean(path)
+
+	// files already in an existing torrent can be scanned again
+	if _, found := tf.paths[path]; found {
+		return nil
+	}
+
+	if len(tf.root) == 0 {
+		tf.root = filepath.Dir(path)
+	}
+
+	root, rootErr := commonRoot(tf.root, path)
+	if rootErr != nil {
+		return rootErr
+	}
+	if root != tf.root {
+		// files read from fsys can't be outside of it
+		if tf.fsys != nil {
+			return fmt.Errorf("can't add %s to the torrent, it is outside of %s", path, tf.root)
+		}
+		tf.root = root
 	}
 
-	tf.paths[relativePath] = size
+	tf.paths[path] = size
 	tf.totalFileSizeBytes = tf.totalFileSizeBytes + size
 
 	tf.files = append(tf.files, metainfo.FileInfo{
@@ -53,20 +104,211 @@ func (tf *torrentFile) AddFile(path string, size int64) {
 		Path:     []string{path},
 		PathUtf8: []string{path},
 	})
+
+	return nil
 }
 
-func (tf *torrentFile) buildFromPathList(info metainfo.Info) (metainfo.Info, error) {
+// relativeTo returns path relative to root, comparing absolute paths when only one of them is absolute
+func relativeTo(root, path string) (string, error) {
+	relPath, relErr := filepath.Rel(root, path)
+	if relErr == nil {
+		return relPath, nil
+	}
+
+	absRoot, absRootErr := filepath.Abs(root)
+	absPath, absPathErr := filepath.Abs(path)
+	if absRootErr == nil && absPathErr == nil {
+		if relPath, relErr = filepath.Rel(absRoot, absPath); relErr == nil {
+			return relPath, nil
+		}
+	}
+
+	return "", fmt.Errorf("can't make %s relative to the torrent root %s: %s", path, root, relErr)
+}
+
+// isOutside returns whether a relative path leaves the folder it is relative to
+func isOutside(relPath string) bool {
+	return relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator))
+}
+
+// commonRoot returns root when it contains path, otherwise the closest absolute folder containing both
+func commonRoot(root, path string) (string, error) {
+	relPath, relErr := relativeTo(root, path)
+	if relErr != nil {
+		return "", relErr
+	}
+	if !isOutside(relPath) {
+		return root, nil
+	}
+
+	absRoot, absRootErr := filepath.Abs(root)
+	if absRootErr != nil {
+		return "", fmt.Errorf("error finding the torrent root for %s: %s", path, absRootErr)
+	}
+	absPath, absPathErr := filepath.Abs(path)
+	if absPathErr != nil {
+		return "", fmt.Errorf("error finding the torrent root for %s: %s", path, absPathErr)
+	}
+
+	for dir := absRoot; ; dir = filepath.Dir(dir) {
+		if relPath, relErr := filepath.Rel(dir, absPath); relErr == nil && !isOutside(relPath) {
+			return dir, nil
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	return "", fmt.Errorf("%s and the torrent root %s don't have a common folder", path, root)
+}
+
+// SetFS sets the filesystem the files are read from, paths are relative to the torrent root
+func (tf *torrentFile) SetFS(fsys fs.FS) {
+	tf.fsys = fsys
+}
+
+// SetWebSeeds sets the URLs of web servers that also serve the torrent files (BEP 19)
+func (tf *torrentFile) SetWebSeeds(urls []string) {
+	tf.mi.UrlList = urls
+}
 
-	info.Name = func() string {
-		// b := filepath.Base(tf.root)
-		// switch b {
-		// case ".", "..", string(filepath.Separator):
-		// 	return metainfo.NoName
-		// default:
-		// 	return b
-		// }
+// SetRootFromPath sets whether the torrent root folder is named after the scanned folder instead of the configured name
+func (tf *torrentFile) SetRootFromPath(rootFromPath bool) {
+	tf.rootFromPath = rootFromPath
+}
+
+// SetCreationDate sets the unix creation date of the torrent, 0 omits it
+func (tf *torrentFile) SetCreationDate(date int64) {
+	tf.mi.CreationDate = date
+}
+
+// SetCreatedBy sets the tool string of the torrent, an empty string omits it
+func (tf *torrentFile) SetCreatedBy(createdBy string) {
+	tf.mi.CreatedBy = createdBy
+}
+
+// SetManifest sets the file a sha256sum compatible manifest of the torrent files is written to
+func (tf *torrentFile) SetManifest(fileName string) {
+	tf.manifest = fileName
+}
+
+// SetManifestHash sets the hash the manifest is written with, see ManifestHash. Pieces are always hashed with
+// sha1 as v1 torrents require.
+func (tf *torrentFile) SetManifestHash(newHash func() hash.Hash) {
+	tf.manifestHash = newHash
+}
+
+// SetCheckpoint sets the file the hashed pieces are saved to while hashing. When it holds the pieces of an
+// interrupted run of the same files they're reused, and it's removed once the torrent is created.
+func (tf *torrentFile) SetCheckpoint(fileName string) {
+	tf.checkpoint = fileName
+}
+
+// SetPrivate sets whether the torrent is private, public torrents can use DHT and PEX
+func (tf *torrentFile) SetPrivate(private bool) {
+	tf.private = private
+}
+
+// SetReadRate limits how fast files are read while generating pieces, 0 means unlimited
+func (tf *torrentFile) SetReadRate(bytesPerSecond int64) {
+	tf.readRate = bytesPerSecond
+}
+
+// SetHashWorkers sets how many pieces are hashed at the same time, 0 uses one worker per CPU
+func (tf *torrentFile) SetHashWorkers(workers int) {
+	tf.hashWorkers = workers
+}
+
+// AddFileFrom adds a file to the torrent at path whose contents are read from source
+func (tf *torrentFile) AddFileFrom(path, source string, size int64) error {
+	if addErr := tf.AddFile(path, size); addErr != nil {
+		return addErr
+	}
+
+	// keyed by absolute path as the torrent root may become absolute when files are added outside of it
+	absPath, absErr := filepath.Abs(path)
+	if absErr != nil {
+		return fmt.Errorf("error adding %s to the torrent: %s", path, absErr)
+	}
+	tf.sources[absPath] = source
+
+	return nil
+}
+
+// source returns the file the contents of path are read from
+func (tf *torrentFile) source(path string) string {
+	if absPath, absErr := filepath.Abs(path); absErr == nil {
+		if source, ok := tf.sources[absPath]; ok {
+			return source
+		}
+	}
+	return path
+}
+
+// open opens a file of the torrent from the local filesystem, or from fsys when set
+func (tf *torrentFile) open(path string) (fs.File, error) {
+	if tf.fsys == nil {
+		f, openErr := os.Open(path)
+		if openErr != nil {
+			return nil, openErr
+		}
+		return f, nil
+	}
+
+	name, nameErr := tf.fsysName(path)
+	if nameErr != nil {
+		return nil, nameErr
+	}
+
+	return tf.fsys.Open(name)
+}
+
+// stat returns the file info of a file of the torrent from the local filesystem, or from fsys when set
+func (tf *torrentFile) stat(path string) (fs.FileInfo, error) {
+	if tf.fsys == nil {
+		return os.Stat(path)
+	}
+
+	name, nameErr := tf.fsysName(path)
+	if nameErr != nil {
+		return nil, nameErr
+	}
+
+	return fs.Stat(tf.fsys, name)
+}
+
+// fsysName converts a path under the torrent root to a name in fsys
+func (tf *torrentFile) fsysName(path string) (string, error) {
+	relPath, relErr := relativeTo(tf.root, path)
+	if relErr != nil {
+		return "", relErr
+	}
+
+	return filepath.ToSlash(relPath), nil
+}
+
+// RootName returns the name of the torrent root folder, the base name of root when rootFromPath is set
+// and root has one, otherwise name or the default name
+func RootName(root, name string, rootFromPath bool) string {
+	if rootFromPath {
+		b := filepath.Base(root)
+		switch b {
+		case ".", "..", string(filepath.Separator):
+		default:
+			return b
+		}
+	}
+
+	if len(name) == 0 {
 		return torrentFsBase
-	}()
+	}
+
+	return name
+}
+
+func (tf *torrentFile) buildFromPathList(info metainfo.Info) (metainfo.Info, error) {
+
+	info.Name = RootName(tf.root, tf.name, tf.rootFromPath)
 
 	info.Files = nil
 
@@ -83,7 +325,7 @@ func (tf *torrentFile) buildFromPathList(info metainfo.Info) (metainfo.Info, err
 
 			path := file.Path[0]
 
-			fi, statErr := os.Stat(path)
+			fi, statErr := tf.stat(tf.source(path))
 			if os.IsNotExist(statErr) || len(path) == 0 {
 				return info, fmt.Errorf("path doesn't exist %s", statErr)
 			}
@@ -93,9 +335,9 @@ func (tf *torrentFile) buildFromPathList(info metainfo.Info) (metainfo.Info, err
 				return info, nil
 			}
 
-			relPath, err := filepath.Rel(tf.root, path)
+			relPath, err := relativeTo(tf.root, path)
 			if err != nil {
-				return info, fmt.Errorf("error getting relative path: %s", err)
+				return info, err
 			}
 
 			info.Files = append(info.Files, metainfo.FileInfo{
@@ -116,20 +358,34 @@ func (tf *torrentFile) Create(outFile string) error {
 		fmt.Println("Creating torrent file", outFile)
 	}
 
-	pieceLength := metainfo.ChoosePieceLength(tf.totalFileSizeBytes)
+	if len(tf.paths) == 0 {
+		return fmt.Errorf("error building torrent: no files added")
+	}
+
+	pieceLength := tf.pieceLength
+	if pieceLength == 0 {
+		pieceLength = metainfo.ChoosePieceLength(tf.totalFileSizeBytes)
+	}
+
+	// the private key is omitted entirely for public torrents
+	var private *bool
+	if tf.private {
+		private = &tf.private
+	}
 
-	private := true
 	info, buildErr := tf.buildFromPathList(metainfo.Info{
-		Private:     &private,
+		Private:     private,
 		PieceLength: pieceLength,
+		Source:      tf.infoSource,
 	})
 
 	if buildErr != nil {
 		return fmt.Errorf("error building torrent: %s", buildErr)
 	}
 
+	// numbered tracks are listed in order ex: track2 before track10
 	slices.Sort(info.Files, func(l, r metainfo.FileInfo) bool {
-		return strings.Join(l.Path, "/") < strings.Join(r.Path, "/")
+		return natsort.Less(strings.Join(l.Path, "/"), strings.Join(r.Path, "/"))
 	})
 
 	if info.PieceLength == 0 {
@@ -140,21 +396,51 @@ func (tf *torrentFile) Create(outFile string) error {
 		return errors.New("piece length must be non-zero")
 	}
 
-	pr, pw := io.Pipe()
-	go func() {
-		err := writeFiles(tf.root, &info, pw, tf.logOutput)
-		pw.CloseWithError(err)
-	}()
-	defer pr.Close()
+	var manifest io.Writer
+	if len(tf.manifest) > 0 {
+		mf, createErr := os.Create(tf.manifest)
+		if createErr != nil {
+			return fmt.Errorf("error creating manifest: %s", createErr)
+		}
+		defer mf.Close()
+
+		bw := bufio.NewWriter(mf)
+		defer bw.Flush()
+		manifest = bw
+	}
+
+	var hashErr error
+	workers := tf.hashWorkers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	manifestHash := tf.manifestHash
+	if manifestHash == nil {
+		manifestHash = sha256.New
+	}
 
-	var genErr error
-	info.Pieces, genErr = generatePieces(pr, info.PieceLength, nil)
-	if genErr != nil {
-		return fmt.Errorf("error generating pieces: %s", genErr)
+	var cp *checkpointer
+	if len(tf.checkpoint) > 0 {
+		var cpErr error
+		cp, cpErr = newCheckpointer(tf.checkpoint, &info)
+		if cpErr != nil {
+			return cpErr
+		}
 	}
 
-	if genErr != nil {
-		return fmt.Errorf("error generating pieces: %s", genErr)
+	info.Pieces, hashErr = hashPieces(tf.root, tf.source, tf.open, &info, workers, newReadLimiter(tf.readRate), sha1.New, manifest, manifestHash, cp, tf.logOutput)
+	if hashErr != nil {
+		return fmt.Errorf("error generating pieces: %s", hashErr)
+	}
+
+	if cp != nil {
+		if cp.saveErr != nil && tf.logOutput {
+			log.Println("warning:", cp.saveErr)
+		}
+		if removeErr := cp.remove(); removeErr != nil {
+			return removeErr
+		}
 	}
 
 	var bencodeErr error
@@ -163,17 +449,28 @@ func (tf *torrentFile) Create(outFile string) error {
 		return fmt.Errorf("errror bencoding info: %s", bencodeErr)
 	}
 
-	f, openErr := os.OpenFile(outFile, os.O_WRONLY|os.O_CREATE|os.O_CREATE, 0600)
+	// write to a temp file first so a failed write never leaves a partial torrent behind
+	tmpFile := outFile + ".tmp"
+	f, openErr := os.OpenFile(tmpFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if openErr != nil {
 		return fmt.Errorf("error opening file: %s", openErr)
 	}
-	defer f.Close()
 
 	outErr := tf.mi.Write(f)
+	closeErr := f.Close()
+	if outErr == nil {
+		outErr = closeErr
+	}
 	if outErr != nil {
+		os.Remove(tmpFile)
 		return fmt.Errorf("error writing torrent file: %s", outErr)
 	}
 
+	if renameErr := os.Rename(tmpFile, outFile); renameErr != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("error writing torrent file: %s", renameErr)
+	}
+
 	endTime := time.Now()
 	diff := endTime.Sub(startTime)
 	if tf.logOutput {
@@ -184,18 +481,29 @@ func (tf *torrentFile) Create(outFile string) error {
 
 }
 
-// MagnetURL returns the magnet url for the torrent
+// MagnetURL returns the magnet url for the torrent with the trackers and the torrent name, so it can be
+// resolved without the torrent file
 func (tf *torrentFile) MagnetURL() string {
-	return tf.mi.Magnet(nil, nil).String()
+	info, infoErr := tf.mi.UnmarshalInfo()
+	if infoErr != nil {
+		return tf.mi.Magnet(nil, nil).String()
+	}
+
+	return tf.mi.Magnet(nil, &info).String()
 }
 
-// New creates a new TorrentFile
-func New(root, comment string, announce []string, logOutput bool) (TorrentFile, error) {
+// New creates a new TorrentFile, name is the torrent root folder name and defaults to "music".
+// The file paths in the torrent are relative to root, an empty root uses the common ancestor of the added files.
+func New(root, name, comment string, announce []string, logOutput bool) (TorrentFile, error) {
+
+	if len(name) == 0 {
+		name = torrentFsBase
+	}
 
 	mi := metainfo.MetaInfo{
 		AnnounceList: [][]string{},
 		Comment:      comment,
-		CreatedBy:    "github.com/concretelabs/milkdud",
+		CreatedBy:    DefaultCreatedBy,
 		CreationDate: time.Now().Unix(),
 	}
 
@@ -206,109 +514,58 @@ func New(root, comment string, announce []string, logOutput bool) (TorrentFile,
 	tf := torrentFile{
 		mi:        &mi,
 		paths:     map[string]int64{},
+		sources:   map[string]string{},
 		files:     []metainfo.FileInfo{},
 		root:      root,
+		name:      name,
 		announce:  announce,
 		logOutput: logOutput,
+		private:   true,
 	}
 
 	return &tf, nil
 }
 
-// writeFiles writes the files in info to as fast as possible
-func writeFiles(root string, info *metainfo.Info, w io.Writer, logOutput bool) error {
-
-	files := info.UpvertedFiles()
-	c := make(chan metainfo.FileInfo)
-	results := make(chan string)
-
-	worker := func(i int, wg *sync.WaitGroup) error {
-		g := new(errgroup.Group)
-
-		g.Go(func() error {
-			for fi := range c {
-				p := filepath.Join(root, strings.Join(fi.Path, string(filepath.Separator)))
-
-				f, err := os.Open(p)
-				if err != nil {
-					return fmt.Errorf("error opening %v: %s", fi, err)
-				}
-
-				wn, err := io.CopyN(w, f, fi.Length)
-				f.Close()
-
-				if wn != fi.Length {
-					return fmt.Errorf("error copying %v: %s", fi, err)
-				}
-
-				results <- p
-			}
-			wg.Done()
-			return nil
-		})
-
-		if err := g.Wait(); err != nil {
-			return err
-		}
-
-		return nil
+// Open loads an existing torrent so files can be added to it, the files of the torrent are expected in root.
+// The trackers, comment, private flag, source and piece length are kept, the pieces are hashed again on Create.
+func Open(fileName, root string, logOutput bool) (TorrentFile, error) {
+	mi, loadErr := metainfo.LoadFromFile(fileName)
+	if loadErr != nil {
+		return nil, fmt.Errorf("error loading torrent file: %s", loadErr)
 	}
 
-	createWorkerPool := func(workerCnt int) {
-		var wg sync.WaitGroup
-		for i := 0; i < workerCnt; i++ {
-			wg.Add(1)
-			worker(i, &wg)
-		}
-		wg.Wait()
-		close(results)
+	var info metainfo.Info
+	if bencodeErr := bencode.Unmarshal(mi.InfoBytes, &info); bencodeErr != nil {
+		return nil, fmt.Errorf("error decoding torrent info: %s", bencodeErr)
 	}
 
-	result := func(logOutput bool, done chan bool) {
-		for range results {
-			if logOutput {
-				fmt.Printf(".")
-			}
-		}
-		if logOutput {
-			fmt.Printf("\n")
-		}
-		done <- true
+	if len(info.Files) == 0 {
+		return nil, fmt.Errorf("can't add files to single file torrent %s", fileName)
 	}
 
-	// allocate
-	go func() {
-		//  allocate
-		for _, fi := range files {
-			c <- fi
-		}
-		close(c)
-	}()
-
-	done := make(chan bool)
-	go result(logOutput, done)
-
-	workers := runtime.NumCPU()
-	createWorkerPool(workers)
-
-	<-done
+	// the content changes so the creation date is refreshed unless it was left out on purpose
+	if mi.CreationDate > 0 {
+		mi.CreationDate = time.Now().Unix()
+	}
 
-	return nil
-}
+	tf := torrentFile{
+		mi:          mi,
+		paths:       map[string]int64{},
+		sources:     map[string]string{},
+		files:       []metainfo.FileInfo{},
+		root:        root,
+		name:        info.Name,
+		logOutput:   logOutput,
+		private:     info.Private != nil && *info.Private,
+		pieceLength: info.PieceLength,
+		infoSource:  info.Source,
+	}
 
-// generatePieces generates the pieces for the torrent
-func generatePieces(r io.Reader, pieceLength int64, b []byte) ([]byte, error) {
-	for {
-		h := sha1.New()
-		written, err := io.CopyN(h, r, pieceLength)
-		if written > 0 {
-			b = h.Sum(b)
-		}
-		if err == io.EOF {
-			return b, nil
-		}
-		if err != nil {
-			return b, err
+	for _, fi := range info.Files {
+		if addErr := tf.AddFile(filepath.Join(append([]string{root}, fi.Path...)...), fi.Length); addErr != nil {
+			return nil, addErr
 		}
 	}
+
+	return &tf, nil
 }
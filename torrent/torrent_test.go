@@ -0,0 +1,196 @@
+package torrent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// writeTestFiles creates the files at the slash seperated relPaths under dir and returns their paths
+func writeTestFiles(t *testing.T, dir string, relPaths []string) []string {
+	paths := []string{}
+	for _, relPath := range relPaths {
+		p := filepath.Join(dir, filepath.FromSlash(relPath))
+		if mkdirErr := os.MkdirAll(filepath.Dir(p), 0755); mkdirErr != nil {
+			t.Fatal(mkdirErr)
+		}
+		if writeErr := os.WriteFile(p, []byte(relPath), 0644); writeErr != nil {
+			t.Fatal(writeErr)
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+func TestCreateTrackOrder(t *testing.T) {
+	dir := t.TempDir()
+
+	want := []string{}
+	for i := 1; i <= 12; i++ {
+		want = append(want, fmt.Sprintf("Album/Track %d.flac", i))
+	}
+
+	// the files are added in plain string order, track 10 to 12 before track 2
+	relPaths := append([]string{}, want...)
+	sort.Strings(relPaths)
+
+	tf, newErr := New(dir, "", "", nil, false)
+	if newErr != nil {
+		t.Fatal(newErr)
+	}
+	for _, p := range writeTestFiles(t, dir, relPaths) {
+		info, statErr := os.Stat(p)
+		if statErr != nil {
+			t.Fatal(statErr)
+		}
+		if addErr := tf.AddFile(p, info.Size()); addErr != nil {
+			t.Fatalf("AddFile(%s) error = %v", p, addErr)
+		}
+	}
+
+	outFile := filepath.Join(t.TempDir(), "music.torrent")
+	if createErr := tf.Create(outFile); createErr != nil {
+		t.Fatalf("Create() error = %v", createErr)
+	}
+
+	mi, loadErr := metainfo.LoadFromFile(outFile)
+	if loadErr != nil {
+		t.Fatal(loadErr)
+	}
+	info, infoErr := mi.UnmarshalInfo()
+	if infoErr != nil {
+		t.Fatal(infoErr)
+	}
+
+	got := []string{}
+	for _, fi := range info.Files {
+		got = append(got, strings.Join(fi.Path, "/"))
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("info.Files = %v, want %v", got, want)
+	}
+}
+
+func TestMagnetURL(t *testing.T) {
+	dir := t.TempDir()
+	announce := []string{"https://tracker.example.com/announce", "udp://tracker.example.org:6969/announce"}
+
+	tf, newErr := New(dir, "Music Library", "", announce, false)
+	if newErr != nil {
+		t.Fatal(newErr)
+	}
+	for _, p := range writeTestFiles(t, dir, []string{"Album/01 Track.flac", "Album/02 Track.flac"}) {
+		if addErr := tf.AddFile(p, int64(len("Album/01 Track.flac"))); addErr != nil {
+			t.Fatalf("AddFile(%s) error = %v", p, addErr)
+		}
+	}
+
+	outFile := filepath.Join(t.TempDir(), "music.torrent")
+	if createErr := tf.Create(outFile); createErr != nil {
+		t.Fatalf("Create() error = %v", createErr)
+	}
+
+	magnet, parseErr := metainfo.ParseMagnetUri(tf.MagnetURL())
+	if parseErr != nil {
+		t.Fatalf("ParseMagnetUri(%s) error = %v", tf.MagnetURL(), parseErr)
+	}
+
+	if magnet.DisplayName != "Music Library" {
+		t.Errorf("dn = %q, want Music Library", magnet.DisplayName)
+	}
+	sort.Strings(magnet.Trackers)
+	if strings.Join(magnet.Trackers, ",") != strings.Join(announce, ",") {
+		t.Errorf("tr = %v, want %v", magnet.Trackers, announce)
+	}
+
+	mi, loadErr := metainfo.LoadFromFile(outFile)
+	if loadErr != nil {
+		t.Fatal(loadErr)
+	}
+	if magnet.InfoHash != mi.HashInfoBytes() {
+		t.Errorf("xt = %s, want the info hash %s", magnet.InfoHash.HexString(), mi.HashInfoBytes().HexString())
+	}
+}
+
+func TestAddFileSiblingFolders(t *testing.T) {
+	dir := t.TempDir()
+	paths := writeTestFiles(t, dir, []string{"Artist A/Album/01 Track.flac", "Artist B/Album/01 Track.flac"})
+
+	// without a root the torrent starts at the folder of the first file and moves up for the second
+	tf, newErr := New("", "", "", nil, false)
+	if newErr != nil {
+		t.Fatal(newErr)
+	}
+	for _, p := range paths {
+		if addErr := tf.AddFile(p, 28); addErr != nil {
+			t.Fatalf("AddFile(%s) error = %v", p, addErr)
+		}
+	}
+
+	if root := tf.(*torrentFile).root; root != dir {
+		t.Errorf("root = %s, want %s", root, dir)
+	}
+
+	for i, want := range []string{"Artist A/Album/01 Track.flac", "Artist B/Album/01 Track.flac"} {
+		relPath, relErr := relativeTo(dir, paths[i])
+		if relErr != nil {
+			t.Fatalf("relativeTo(%s, %s) error = %v", dir, paths[i], relErr)
+		}
+		if filepath.ToSlash(relPath) != want {
+			t.Errorf("relativeTo(%s, %s) = %s, want %s", dir, paths[i], relPath, want)
+		}
+	}
+
+	outFile := filepath.Join(t.TempDir(), "music.torrent")
+	if createErr := tf.Create(outFile); createErr != nil {
+		t.Fatalf("Create() error = %v", createErr)
+	}
+
+	mi, loadErr := metainfo.LoadFromFile(outFile)
+	if loadErr != nil {
+		t.Fatal(loadErr)
+	}
+	info, infoErr := mi.UnmarshalInfo()
+	if infoErr != nil {
+		t.Fatal(infoErr)
+	}
+
+	got := []string{}
+	for _, fi := range info.Files {
+		got = append(got, strings.Join(fi.Path, "/"))
+	}
+	if want := "Artist A/Album/01 Track.flac,Artist B/Album/01 Track.flac"; strings.Join(got, ",") != want {
+		t.Errorf("info.Files = %v, want %s", got, want)
+	}
+}
+
+func TestCommonRoot(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		root string
+		path string
+		want string
+	}{
+		{filepath.Join(dir, "a"), filepath.Join(dir, "a", "b", "1.flac"), filepath.Join(dir, "a")},
+		{filepath.Join(dir, "a", "b"), filepath.Join(dir, "a", "c", "1.flac"), filepath.Join(dir, "a")},
+		{filepath.Join(dir, "a", "b", "c"), filepath.Join(dir, "a", "d", "1.flac"), filepath.Join(dir, "a")},
+		{filepath.Join(dir, "music"), filepath.Join(dir, "music2", "1.flac"), dir}, // a shared prefix isn't a shared folder
+	}
+
+	for _, tt := range tests {
+		got, rootErr := commonRoot(tt.root, tt.path)
+		if rootErr != nil {
+			t.Errorf("commonRoot(%s, %s) error = %v", tt.root, tt.path, rootErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("commonRoot(%s, %s) = %s, want %s", tt.root, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// trackersCacheTTL is how long a fetched tracker list is used before it is fetched again
+	trackersCacheTTL = 24 * time.Hour
+
+	// trackersTimeout is how long the tracker list server gets to answer
+	trackersTimeout = 30 * time.Second
+)
+
+// loadTrackers loads a newline seperated tracker list from a URL or a file. A URL is cached on disk and
+// the cached list is used while it's fresh, or when fetching fails so offline runs still work.
+func loadTrackers(source string) ([]string, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		contents, readErr := os.ReadFile(source)
+		if readErr != nil {
+			return nil, fmt.Errorf("error reading tracker list: %s", readErr)
+		}
+		return parseTrackers(source, contents)
+	}
+
+	cacheDir, cacheErr := os.UserCacheDir()
+	if cacheErr != nil {
+		return nil, cacheErr
+	}
+
+	sum := sha1.Sum([]byte(source))
+	cacheFile := filepath.Join(cacheDir, "milkdud", "trackers", hex.EncodeToString(sum[:])+".txt")
+
+	info, statErr := os.Stat(cacheFile)
+	if statErr == nil && time.Since(info.ModTime()) < trackersCacheTTL {
+		if cached, readErr := os.ReadFile(cacheFile); readErr == nil {
+			return parseTrackers(source, cached)
+		}
+	}
+
+	contents, fetchErr := fetchTrackers(source)
+	if fetchErr != nil {
+		cached, readErr := os.ReadFile(cacheFile)
+		if readErr != nil {
+			return nil, fetchErr
+		}
+		fmt.Fprintf(os.Stderr, "warning: %s, using the cached tracker list\n", fetchErr)
+		return parseTrackers(source, cached)
+	}
+
+	// validate before caching so a broken list doesn't replace a good one
+	trackers, parseErr := parseTrackers(source, contents)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	if mkdirErr := os.MkdirAll(filepath.Dir(cacheFile), 0755); mkdirErr != nil {
+		return nil, fmt.Errorf("error creating tracker list cache directory %s", mkdirErr)
+	}
+	if writeErr := os.WriteFile(cacheFile, contents, 0644); writeErr != nil {
+		return nil, fmt.Errorf("error caching tracker list %s", writeErr)
+	}
+
+	return trackers, nil
+}
+
+// fetchTrackers downloads a tracker list
+func fetchTrackers(source string) ([]byte, error) {
+	client := &http.Client{Timeout: trackersTimeout}
+
+	resp, respErr := client.Get(source)
+	if respErr != nil {
+		return nil, fmt.Errorf("error fetching tracker list %s", respErr)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching tracker list %s: %s", source, resp.Status)
+	}
+
+	body, bodyErr := io.ReadAll(resp.Body)
+	if bodyErr != nil {
+		return nil, fmt.Errorf("error reading tracker list %s: %s", source, bodyErr)
+	}
+
+	return body, nil
+}
+
+// parseTrackers parses a tracker list of one announce URL per line, blank lines and # comments are skipped
+func parseTrackers(source string, contents []byte) ([]string, error) {
+	trackers := []string{}
+	seen := map[string]bool{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") || seen[line] {
+			continue
+		}
+
+		if validateErr := validateAnnounce(line); validateErr != nil {
+			return nil, fmt.Errorf("error in tracker list %s: %s", source, validateErr)
+		}
+
+		seen[line] = true
+		trackers = append(trackers, line)
+	}
+
+	if scanErr := scanner.Err(); scanErr != nil {
+		return nil, fmt.Errorf("error reading tracker list %s: %s", source, scanErr)
+	}
+
+	if len(trackers) == 0 {
+		return nil, fmt.Errorf("tracker list %s has no trackers", source)
+	}
+
+	return trackers, nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long to wait after the last filesystem event before rescanning
+const watchDebounce = 2 * time.Second
+
+// watch watches scanPath for changes and rescans affected folders until interrupted, metrics are updated after each
+// rescan. Rescanned folders are included in the stats by the same filter as the scan.
+func watch(scanPath string, stats Stats, albums []MusicFolder, metrics *scanMetrics, filter *folderFilter, logOutput bool) error {
+	watcher, watcherErr := fsnotify.NewWatcher()
+	if watcherErr != nil {
+		return fmt.Errorf("error creating watcher: %s", watcherErr)
+	}
+	defer watcher.Close()
+
+	if addErr := watchTree(watcher, scanPath); addErr != nil {
+		return addErr
+	}
+
+	// folders currently included in stats by path
+	folders := map[string]*MusicFolder{}
+	for i := range albums {
+		folders[albums[i].Path] = &albums[i]
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+	pending := map[string]bool{}
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+
+	if logOutput {
+		fmt.Println("Watching", scanPath, "for changes, press Ctrl-C to exit")
+	}
+
+	for {
+		select {
+		case <-interrupt:
+			if logOutput {
+				fmt.Println("Stopped watching", scanPath)
+			}
+			return nil
+
+		case watchErr, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintln(os.Stderr, "watch error:", watchErr)
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			dir := event.Name
+			info, statErr := os.Stat(event.Name)
+			if statErr != nil || !info.IsDir() {
+				dir = filepath.Dir(event.Name)
+			} else if event.Op&fsnotify.Create == fsnotify.Create {
+				if addErr := watchTree(watcher, event.Name); addErr != nil {
+					fmt.Fprintln(os.Stderr, addErr)
+				}
+			}
+
+			// a removed or renamed folder takes the folders below it out of the stats too
+			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				for p := range folders {
+					if isWithin(event.Name, p) {
+						pending[p] = true
+					}
+				}
+			}
+
+			// a folder includes the files of its sub folders so every parent needs a rescan too
+			for _, p := range parentFolders(scanPath, dir) {
+				pending[p] = true
+			}
+
+			debounce.Reset(watchDebounce)
+
+		case <-debounce.C:
+			before := stats
+
+			rescan(scanPath, pending, folders, &stats, filter)
+			pending = map[string]bool{}
+			stats.summarize()
+
+			metrics.update(stats, folderSizes(folders))
+			if logOutput {
+				printStatsDelta(before, stats)
+			}
+		}
+	}
+}
+
+// rescan crawls the pending folders again and updates folders and stats. Removed folders and folders now left out of
+// the scan are dropped, other errors are reported and the folder is left out until it's rescanned.
+func rescan(scanPath string, pending map[string]bool, folders map[string]*MusicFolder, stats *Stats, filter *folderFilter) {
+	// each rescan counts its own files for -max-files
+	rescannedFiles := &fileCounter{}
+	for p := range pending {
+		if old, ok := folders[p]; ok {
+			stats.removeFolder(old)
+			delete(folders, p)
+		}
+
+		mf, crawlErr := crawlPath(scanPath, p, rescannedFiles)
+		if errors.Is(crawlErr, fs.ErrNotExist) || errors.Is(crawlErr, errAlbumFolderMissing) || isIgnoredDir(crawlErr) {
+			// the folder was removed or is now left out of the scan
+			continue
+		}
+		if crawlErr != nil {
+			stats.Errors = stats.Errors + 1
+			fmt.Fprintln(os.Stderr, crawlErr)
+			continue
+		}
+
+		rel, _ := filepath.Rel(scanPath, p)
+		if filter.accept(mf, filepath.ToSlash(rel)) == folderIncluded {
+			mf.assess()
+			stats.addFolder(mf)
+			folders[p] = mf
+		}
+	}
+}
+
+// watchTree adds root and every directory below it to the watcher
+func watchTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if addErr := watcher.Add(p); addErr != nil {
+				return fmt.Errorf("error watching %s: %s", p, addErr)
+			}
+		}
+
+		return nil
+	})
+}
+
+// parentFolders returns dir and each of its parents below scanPath
+func parentFolders(scanPath, dir string) []string {
+	folders := []string{}
+
+	for dir != scanPath && isWithin(scanPath, dir) {
+		folders = append(folders, dir)
+		dir = filepath.Dir(dir)
+	}
+
+	return folders
+}
+
+// isWithin returns true if p is dir or below it, comparing whole path elements so /music2 isn't within /music
+func isWithin(dir, p string) bool {
+	rel, relErr := filepath.Rel(dir, p)
+	return relErr == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// printStatsDelta prints the changes between two sets of stats
+func printStatsDelta(before, after Stats) {
+	fmt.Printf("%s Folders: %d (%+d) Files: %d (%+d) Total file size: %s (%+d bytes)\n",
+		time.Now().Format(time.RFC3339),
+		after.FolderCnt, after.FolderCnt-before.FolderCnt,
+		after.TotalFiles, after.TotalFiles-before.TotalFiles,
+		after.TotalFileSize, after.TotalFileSizeBytes-before.TotalFileSizeBytes,
+	)
+}
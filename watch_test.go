@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParentFolders(t *testing.T) {
+	scanPath := filepath.FromSlash("/music")
+
+	tests := []struct {
+		dir  string
+		want []string
+	}{
+		{"/music/Artist/Album", []string{"/music/Artist/Album", "/music/Artist"}},
+		{"/music/Album", []string{"/music/Album"}},
+		{"/music", []string{}},
+		{"/music2/Album", []string{}}, // a sibling sharing the prefix isn't below the scan path
+		{"/other/Album", []string{}},
+	}
+
+	for _, tt := range tests {
+		got := parentFolders(scanPath, filepath.FromSlash(tt.dir))
+		want := []string{}
+		for _, p := range tt.want {
+			want = append(want, filepath.FromSlash(p))
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("parentFolders(%s) = %v, want %v", tt.dir, got, want)
+		}
+	}
+}
+
+func TestFolderFilterAccept(t *testing.T) {
+	defer func(maxDepth int) { *flagMaxDepth = maxDepth }(*flagMaxDepth)
+	*flagMaxDepth = 2
+
+	filter := &folderFilter{minAlbumSize: 1000}
+	album := &MusicFolder{Path: "/music/Artist/Album", FlacCnt: 1, TotalBytes: 5000, HasAccurip: true}
+
+	tests := []struct {
+		name string
+		mf   *MusicFolder
+		rel  string
+		want folderVerdict
+	}{
+		{"included", album, "Artist/Album", folderIncluded},
+		{"already filtered by the walk", album, "", folderIncluded},
+		{"junk folder", album, "Artist/@eaDir", folderExcluded},
+		{"below a junk folder", album, "@eaDir/Album", folderExcluded},
+		{"too deep", album, "Artist/Album/CD1", folderExcluded},
+		{"too small", &MusicFolder{Path: "/music/Small", FlacCnt: 1, TotalBytes: 10, HasAccurip: true}, "Small", folderSizeFiltered},
+		{"no accurip log", &MusicFolder{Path: "/music/NoLog", FlacCnt: 1, TotalBytes: 5000}, "NoLog", folderNoAccurip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filter.accept(tt.mf, tt.rel); got != tt.want {
+				t.Errorf("accept(%s, %q) = %d, want %d", tt.mf.Path, tt.rel, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRescan(t *testing.T) {
+	scanPath := t.TempDir()
+	for name, data := range map[string][]byte{
+		"Album/01 Track.flac":   testFlac(60),
+		"Album/rip.log":         testEACLog("rescan-", "All tracks accurately ripped"),
+		"Corrupt/01 Track.flac": testFlac(60),
+		"Corrupt/rip.log.gz":    append([]byte{0x1f, 0x8b}, "not gzip"...),
+	} {
+		p := filepath.Join(scanPath, filepath.FromSlash(name))
+		if mkdirErr := os.MkdirAll(filepath.Dir(p), 0755); mkdirErr != nil {
+			t.Fatal(mkdirErr)
+		}
+		if writeErr := os.WriteFile(p, data, 0644); writeErr != nil {
+			t.Fatal(writeErr)
+		}
+	}
+
+	// every folder was included by the scan, Removed has since been deleted
+	stats := Stats{}
+	folders := map[string]*MusicFolder{}
+	pending := map[string]bool{}
+	for _, name := range []string{"Album", "Corrupt", "Removed"} {
+		mf := &MusicFolder{Path: filepath.Join(scanPath, name), FlacCnt: 1, FileCnt: 1, TotalBytes: 100, HasAccurip: true}
+		stats.addFolder(mf)
+		folders[mf.Path] = mf
+		pending[mf.Path] = true
+	}
+
+	rescan(scanPath, pending, folders, &stats, &folderFilter{})
+
+	// the removed folder is dropped quietly, the unreadable log is an error and leaves its folder out
+	if _, found := folders[filepath.Join(scanPath, "Album")]; !found || len(folders) != 1 {
+		t.Errorf("folders = %v, want only Album", folders)
+	}
+	if stats.FolderCnt != 1 {
+		t.Errorf("FolderCnt = %d, want 1", stats.FolderCnt)
+	}
+	if stats.Errors != 1 {
+		t.Errorf("Errors = %d, want 1 for the corrupt log", stats.Errors)
+	}
+}
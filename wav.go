@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// wavFmtSize is the length of the fields of a PCM fmt chunk, the fmt chunks of other formats extend it
+const wavFmtSize = 16
+
+// wavDuration reads the RIFF chunks of a WAV file up to the audio data and returns the duration in seconds
+func wavDuration(fsys fs.FS, p string) (float64, error) {
+	f, openErr := fsys.Open(p)
+	if openErr != nil {
+		return 0, openErr
+	}
+	defer f.Close()
+
+	header := make([]byte, 12)
+	if _, readErr := io.ReadFull(f, header); readErr != nil {
+		return 0, fmt.Errorf("error reading wav header %s: %s", p, readErr)
+	}
+
+	if !bytes.Equal(header[0:4], []byte("RIFF")) || !bytes.Equal(header[8:12], []byte("WAVE")) {
+		return 0, fmt.Errorf("not a wav file: %s", p)
+	}
+
+	byteRate := uint32(0)
+	chunk := make([]byte, 8)
+	for {
+		if _, readErr := io.ReadFull(f, chunk); readErr != nil {
+			return 0, fmt.Errorf("error reading wav chunk %s: %s", p, readErr)
+		}
+
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+
+		switch id {
+		case "fmt ":
+			// audio format, channels and sample rate come before the byte rate, only the fields every fmt chunk has are
+			// read and the extension of the other formats is skipped
+			if size < wavFmtSize {
+				return 0, fmt.Errorf("invalid wav fmt chunk: %s", p)
+			}
+			fmtChunk := make([]byte, wavFmtSize)
+			if _, readErr := io.ReadFull(f, fmtChunk); readErr != nil {
+				return 0, fmt.Errorf("error reading wav fmt chunk %s: %s", p, readErr)
+			}
+			if _, skipErr := io.CopyN(io.Discard, f, size-wavFmtSize); skipErr != nil {
+				return 0, fmt.Errorf("error reading wav fmt chunk %s: %s", p, skipErr)
+			}
+			byteRate = binary.LittleEndian.Uint32(fmtChunk[8:12])
+
+		case "data":
+			if byteRate == 0 {
+				return 0, fmt.Errorf("unknown wav duration: %s", p)
+			}
+			return float64(size) / float64(byteRate), nil
+
+		default:
+			if _, skipErr := io.CopyN(io.Discard, f, size); skipErr != nil {
+				return 0, fmt.Errorf("error reading wav chunk %s: %s", p, skipErr)
+			}
+		}
+
+		// chunks are padded to an even size
+		if size%2 == 1 && id != "data" {
+			if _, skipErr := io.CopyN(io.Discard, f, 1); skipErr != nil {
+				return 0, fmt.Errorf("error reading wav chunk %s: %s", p, skipErr)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+	"testing/fstest"
+)
+
+// testWav returns a WAV file with a fmt chunk of fmtSize bytes and the data chunk header of seconds of 44.1kHz stereo
+func testWav(fmtSize uint32, seconds int) []byte {
+	byteRate := uint32(44100 * 2 * 2)
+
+	b := append([]byte("RIFF"), 0, 0, 0, 0)
+	b = append(b, "WAVEfmt "...)
+	b = binary.LittleEndian.AppendUint32(b, fmtSize)
+
+	fmtChunk := make([]byte, 16)
+	binary.LittleEndian.PutUint16(fmtChunk[0:2], 1)
+	binary.LittleEndian.PutUint16(fmtChunk[2:4], 2)
+	binary.LittleEndian.PutUint32(fmtChunk[4:8], 44100)
+	binary.LittleEndian.PutUint32(fmtChunk[8:12], byteRate)
+	binary.LittleEndian.PutUint16(fmtChunk[12:14], 4)
+	binary.LittleEndian.PutUint16(fmtChunk[14:16], 16)
+	b = append(b, fmtChunk...)
+	if fmtSize > 16 && fmtSize < 64 {
+		b = append(b, make([]byte, fmtSize-16)...)
+	}
+
+	b = append(b, "data"...)
+	return binary.LittleEndian.AppendUint32(b, byteRate*uint32(seconds))
+}
+
+func TestWavDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    float64
+		wantErr bool
+	}{
+		{"pcm", testWav(16, 60), 60, false},
+		{"extended fmt", testWav(18, 60), 60, false},
+		{"short fmt", testWav(12, 60), 0, true},
+		{"huge fmt", testWav(0xfffffff0, 60), 0, true}, // the size is only skipped, not allocated
+		{"not a wav", []byte("RIFF\x00\x00\x00\x00AVI "), 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, durationErr := wavDuration(fstest.MapFS{"track.wav": {Data: tt.data}}, "track.wav")
+			if (durationErr != nil) != tt.wantErr {
+				t.Fatalf("wavDuration() error = %v, want error %v", durationErr, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("wavDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
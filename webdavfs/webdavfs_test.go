@@ -0,0 +1,127 @@
+package webdavfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testServer serves a music folder holding track.flac with data, ranges sets whether Range headers are honored.
+// gets counts the GET requests.
+func testServer(t *testing.T, data []byte, ranges bool, gets *int64) FS {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PROPFIND":
+			var prop string
+			switch strings.TrimSuffix(r.URL.Path, "/") {
+			case "/music":
+				prop = `<D:resourcetype><D:collection/></D:resourcetype>`
+			case "/music/track.flac":
+				prop = fmt.Sprintf(`<D:resourcetype/><D:getcontentlength>%d</D:getcontentlength>`, len(data))
+			default:
+				http.NotFound(w, r)
+				return
+			}
+
+			w.WriteHeader(http.StatusMultiStatus)
+			fmt.Fprintf(w, `<?xml version="1.0"?><D:multistatus xmlns:D="DAV:"><D:response><D:href>%s</D:href>`+
+				`<D:propstat><D:prop>%s</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response></D:multistatus>`,
+				r.URL.Path, prop)
+
+		case http.MethodGet:
+			atomic.AddInt64(gets, 1)
+			if ranges {
+				http.ServeContent(w, r, "track.flac", time.Time{}, bytes.NewReader(data))
+				return
+			}
+			w.Write(data)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	u, parseErr := url.Parse(strings.Replace(server.URL, "http://", "webdav://", 1) + "/music")
+	if parseErr != nil {
+		t.Fatal(parseErr)
+	}
+
+	wf, newErr := New(u)
+	if newErr != nil {
+		t.Fatalf("New(%s) error = %v", u, newErr)
+	}
+
+	return wf
+}
+
+func TestReadChunks(t *testing.T) {
+	data := make([]byte, 2*readChunkSize+123)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	var gets int64
+	wf := testServer(t, data, true, &gets)
+
+	got, readErr := fs.ReadFile(wf, "track.flac")
+	if readErr != nil {
+		t.Fatalf("ReadFile() error = %v", readErr)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("ReadFile() read %d bytes that don't match the %d bytes of the file", len(got), len(data))
+	}
+
+	// each request downloads a single chunk
+	if gets != 3 {
+		t.Errorf("%d GET requests, want 3", gets)
+	}
+}
+
+func TestReadWithoutRangeSupport(t *testing.T) {
+	data := []byte("fLaC and the rest of the track")
+
+	var gets int64
+	wf := testServer(t, data, false, &gets)
+
+	got, readErr := fs.ReadFile(wf, "track.flac")
+	if readErr != nil || !bytes.Equal(got, data) {
+		t.Errorf("ReadFile() = %q, %v, want the whole file", got, readErr)
+	}
+
+	f, openErr := wf.Open("track.flac")
+	if openErr != nil {
+		t.Fatal(openErr)
+	}
+	defer f.Close()
+	ra := f.(io.ReaderAt)
+
+	// the start of the file doesn't need a range
+	b := make([]byte, 4)
+	if _, readAtErr := ra.ReadAt(b, 0); readAtErr != nil || string(b) != "fLaC" {
+		t.Errorf("ReadAt(0) = %q, %v, want fLaC", b, readAtErr)
+	}
+
+	if _, readAtErr := ra.ReadAt(b, 8); !errors.Is(readAtErr, errNoRangeSupport) {
+		t.Errorf("ReadAt(8) error = %v, want %v", readAtErr, errNoRangeSupport)
+	}
+}
+
+func TestNewMissingFolder(t *testing.T) {
+	var gets int64
+	wf := testServer(t, nil, true, &gets)
+
+	server := wf.(*webdavFS).base
+	u := &url.URL{Scheme: "webdav", Host: server.Host, Path: "/missing"}
+
+	_, newErr := New(u)
+	if newErr == nil || !strings.Contains(newErr.Error(), "error reading /missing on "+server.Host+": ") {
+		t.Errorf("New() error = %v, want the folder, host and error seperated", newErr)
+	}
+}
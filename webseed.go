@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"concretelabs/milkdud/torrent"
+)
+
+const (
+	// webSeedWorkers is how many web seed files are checked at the same time
+	webSeedWorkers = 8
+
+	// webSeedTimeout is how long a web seed server gets to answer each request
+	webSeedTimeout = 30 * time.Second
+)
+
+// parseWebSeeds parses comma seperated web seed URLs, each is made to end in a / so the torrent name and file path are appended (BEP 19)
+func parseWebSeeds(s string) ([]string, error) {
+	webSeeds := []string{}
+
+	for _, ws := range strings.Split(s, ",") {
+		ws = strings.TrimSpace(ws)
+		if len(ws) == 0 {
+			continue
+		}
+
+		u, parseErr := url.Parse(ws)
+		if parseErr != nil {
+			return nil, fmt.Errorf("invalid web seed URL %s: %s", ws, parseErr)
+		}
+
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("invalid web seed URL %s: scheme must be http or https", ws)
+		}
+
+		if len(u.Host) == 0 {
+			return nil, fmt.Errorf("invalid web seed URL %s: missing host", ws)
+		}
+
+		if !strings.HasSuffix(ws, "/") {
+			ws = ws + "/"
+		}
+
+		webSeeds = append(webSeeds, ws)
+	}
+
+	return webSeeds, nil
+}
+
+// webSeedURL returns the URL a web seed serves a torrent file from, path includes the torrent name
+func webSeedURL(webSeed, path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return webSeed + strings.Join(segments, "/")
+}
+
+// verifyWebSeeds checks every file of a created torrent can be downloaded from the web seeds with the right size
+func verifyWebSeeds(torrentFileName string, webSeeds []string) ([]error, error) {
+	d, loadErr := torrent.Load(torrentFileName)
+	if loadErr != nil {
+		return nil, loadErr
+	}
+
+	client := &http.Client{Timeout: webSeedTimeout}
+
+	type check struct {
+		url    string
+		length int64
+	}
+
+	c := make(chan check)
+	results := make(chan error)
+
+	worker := func(wg *sync.WaitGroup) {
+		for chk := range c {
+			results <- verifyWebSeedFile(client, chk.url, chk.length)
+		}
+		wg.Done()
+	}
+
+	go func() {
+		var wg sync.WaitGroup
+		for i := 0; i < webSeedWorkers; i++ {
+			wg.Add(1)
+			go worker(&wg)
+		}
+		wg.Wait()
+		close(results)
+	}()
+
+	// allocate
+	go func() {
+		for _, ws := range webSeeds {
+			for _, file := range d.Files {
+				c <- check{webSeedURL(ws, file.Path), file.Length}
+			}
+		}
+		close(c)
+	}()
+
+	errs := []error{}
+	for err := range results {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// checks finish in any order
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+
+	return errs, nil
+}
+
+// verifyWebSeedFile issues a HEAD request for a web seed file and checks its size
+func verifyWebSeedFile(client *http.Client, u string, length int64) error {
+	resp, reqErr := client.Head(u)
+	if reqErr != nil {
+		// the url is already in the message
+		var urlErr *url.Error
+		if errors.As(reqErr, &urlErr) {
+			reqErr = urlErr.Err
+		}
+		return fmt.Errorf("web seed %s: %s", u, reqErr)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("web seed %s: %s", u, resp.Status)
+	}
+
+	if resp.ContentLength != length {
+		return fmt.Errorf("web seed %s: size is %d bytes, expected %d", u, resp.ContentLength, length)
+	}
+
+	return nil
+}
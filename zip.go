@@ -0,0 +1,112 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+)
+
+// crawlZip counts the flac files inside a zip archive and detects the TOCID of any rip log in it.
+// The contents are only counted, the archive entries are not added to the folder files as they
+// can't be added to a torrent without extracting them, so their size is kept out of the folder total.
+func crawlZip(fsys fs.FS, p string, mf *MusicFolder) error {
+	f, openErr := fsys.Open(p)
+	if openErr != nil {
+		return openErr
+	}
+	defer f.Close()
+
+	info, statErr := f.Stat()
+	if statErr != nil {
+		return statErr
+	}
+
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		return fmt.Errorf("%s does not support random access", p)
+	}
+
+	zr, zipErr := zip.NewReader(ra, info.Size())
+	if zipErr != nil {
+		return zipErr
+	}
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		switch fileTypeOf(f.Name) {
+		case FileTypeFlac:
+			if !audioFormats[FileTypeFlac] {
+				break
+			}
+
+			mf.ArchiveBytes = mf.ArchiveBytes + int64(f.UncompressedSize64)
+			mf.FlacCnt = mf.FlacCnt + 1
+
+		case FileTypeLog, FileTypeAccurip:
+			rc, entryErr := f.Open()
+			if entryErr != nil {
+				return fmt.Errorf("error opening %s: %s", f.Name, entryErr)
+			}
+
+			contents, readErr := io.ReadAll(rc)
+			rc.Close()
+			if readErr != nil {
+				return fmt.Errorf("error reading %s: %s", f.Name, readErr)
+			}
+
+			result, accuripErr := detectAccurip(contents)
+			if accuripErr != nil {
+				return fmt.Errorf("error reading accurip log file %s: %s", f.Name, accuripErr)
+			}
+
+			mf.addAccurip(result)
+		}
+	}
+
+	return nil
+}
+
+// isZipAlbum returns true if fp is a zip archive crawled as an album folder with -zip-albums
+func isZipAlbum(fp string) bool {
+	return *flagZipAlbums && fileTypeOf(fp) == FileTypeZip
+}
+
+// crawlZipAlbum crawls the zip archive at fp like a folder, the files are reported with their path inside the
+// archive ex: Album.zip/01.flac. The folder is marked as an archive so it's left out of torrents.
+func crawlZipAlbum(fsys fs.FS, root, fp string, files *fileCounter) (*MusicFolder, error) {
+	p := filepath.Join(root, filepath.FromSlash(fp))
+
+	f, openErr := fsys.Open(fp)
+	if openErr != nil {
+		return nil, fmt.Errorf("error reading zip file %s: %w", p, openErr)
+	}
+	defer f.Close()
+
+	info, statErr := f.Stat()
+	if statErr != nil {
+		return nil, fmt.Errorf("error reading zip file %s: %w", p, statErr)
+	}
+
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		return nil, fmt.Errorf("%s does not support random access", p)
+	}
+
+	zr, zipErr := zip.NewReader(ra, info.Size())
+	if zipErr != nil {
+		return nil, fmt.Errorf("error reading zip file %s: %w", p, zipErr)
+	}
+
+	mf, crawlErr := crawlFolder(zr, p, ".", files)
+	if crawlErr != nil {
+		return nil, crawlErr
+	}
+	mf.Archive = true
+
+	return mf, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"testing/fstest"
+)
+
+// testZip returns a zip archive of the files by name
+func testZip(t *testing.T, files map[string][]byte) []byte {
+	b := bytes.Buffer{}
+	zw := zip.NewWriter(&b)
+	for name, data := range files {
+		w, createErr := zw.Create(name)
+		if createErr != nil {
+			t.Fatal(createErr)
+		}
+		if _, writeErr := w.Write(data); writeErr != nil {
+			t.Fatal(writeErr)
+		}
+	}
+	if closeErr := zw.Close(); closeErr != nil {
+		t.Fatal(closeErr)
+	}
+	return b.Bytes()
+}
+
+func TestCrawlZip(t *testing.T) {
+	defer func(scanZip bool) { *flagScanZip = scanZip }(*flagScanZip)
+	defer func(formats map[FileType]bool) { audioFormats = formats }(audioFormats)
+	*flagScanZip = true
+
+	flac := testFlac(60)
+	fsys := fstest.MapFS{
+		"Album/album.zip": {Data: testZip(t, map[string][]byte{
+			"01 Track.flac": flac,
+			"rip.log":       testEACLog("crawl-zip-", "All tracks accurately ripped"),
+		})},
+	}
+
+	// the flac inside is counted but kept out of the total, nothing in the archive can be added to a torrent
+	mf, crawlErr := crawlFolder(fsys, "/crawl-zip", "Album", nil)
+	if crawlErr != nil {
+		t.Fatalf("crawlFolder() error = %v", crawlErr)
+	}
+	if mf.FlacCnt != 1 || mf.ArchiveBytes != int64(len(flac)) {
+		t.Errorf("FlacCnt = %d ArchiveBytes = %d, want 1 and %d", mf.FlacCnt, mf.ArchiveBytes, len(flac))
+	}
+	if mf.TotalBytes != 0 || mf.FileCnt != 0 || len(mf.Files) != 0 {
+		t.Errorf("TotalBytes = %d FileCnt = %d Files = %v, want no files", mf.TotalBytes, mf.FileCnt, mf.Files)
+	}
+	if !mf.HasAccurip || mf.TocID != "crawl-zip-" {
+		t.Errorf("HasAccurip = %v TocID = %q, want the TOCID of the log", mf.HasAccurip, mf.TocID)
+	}
+
+	// -formats applies inside archives too
+	audioFormats = map[FileType]bool{FileTypeWav: true}
+	mf, crawlErr = crawlFolder(fsys, "/crawl-zip-formats", "Album", nil)
+	if crawlErr != nil {
+		t.Fatalf("crawlFolder() error = %v", crawlErr)
+	}
+	if mf.FlacCnt != 0 || mf.ArchiveBytes != 0 {
+		t.Errorf("FlacCnt = %d ArchiveBytes = %d without flac in -formats, want 0", mf.FlacCnt, mf.ArchiveBytes)
+	}
+}